@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"os"
 	"sync"
 	"time"
 
@@ -12,9 +13,11 @@ import (
 	"github.com/codegangsta/cli"
 	"github.com/golang/glog"
 	"github.com/rs/xhandler"
+	"github.com/rs/xmux"
 
 	"tower.pro/renderer/compiler"
 	"tower.pro/renderer/renderer"
+	"tower.pro/renderer/sessions"
 	"tower.pro/renderer/storage"
 	"tower.pro/renderer/watcher"
 
@@ -46,6 +49,11 @@ var Web = cli.Command{
 			Name:  "routes",
 			Usage: "file containing routes in yaml format",
 		},
+		cli.StringFlag{
+			Name:   "env",
+			Usage:  "environment of routes overlays (eq. routes.prod.yaml)",
+			EnvVar: "RENDERER_ENV",
+		},
 		cli.StringFlag{
 			Name:  "components",
 			Usage: "directory containing components",
@@ -75,12 +83,50 @@ var Web = cli.Command{
 			Usage: "renderer interface listening address",
 			Value: "127.0.0.1:6660",
 		},
+		cli.BoolFlag{
+			Name:  "preload",
+			Usage: "preload styles and scripts using link headers",
+		},
+		cli.BoolFlag{
+			Name:  "gzip",
+			Usage: "compress responses using gzip",
+		},
+		cli.IntFlag{
+			Name:  "gzip-min-size",
+			Usage: "minimum size of compressed responses",
+			Value: 1024,
+		},
 		cli.DurationFlag{
 			Name:  "render-timeout",
 			Usage: "component render timeout",
 			Value: 5 * time.Second,
 		},
 
+		// Session options
+		cli.StringSliceFlag{
+			Name:   "session-key",
+			EnvVar: "RENDERER_SESSION_KEYS",
+			Usage:  "session cookies key, first signs new cookies (enables sessions)",
+		},
+		cli.StringFlag{
+			Name:  "session-name",
+			Usage: "session cookie name",
+			Value: "session",
+		},
+		cli.DurationFlag{
+			Name:  "session-max-age",
+			Usage: "session expiration time",
+			Value: 30 * 24 * time.Hour,
+		},
+		cli.BoolFlag{
+			Name:  "session-secure",
+			Usage: "send session cookie only over HTTPS",
+		},
+		cli.BoolFlag{
+			Name:  "session-encrypt",
+			Usage: "encrypt session cookies",
+		},
+
 		// HTTP server flags
 		cli.DurationFlag{
 			Name:   "renderer-read-timeout",
@@ -101,6 +147,11 @@ var Web = cli.Command{
 			EnvVar: "DEBUG_ADDR",
 			Usage:  "debug listening address",
 		},
+		cli.BoolFlag{
+			Name:   "debug",
+			EnvVar: "DEBUG",
+			Usage:  "enable debug response headers",
+		},
 		cli.BoolFlag{
 			Name:   "tracing",
 			EnvVar: "TRACING",
@@ -136,8 +187,33 @@ var Web = cli.Command{
 			DefaultWebOptions = append(DefaultWebOptions, renderer.WithTracing())
 		}
 
+		if c.Bool("debug") {
+			DefaultWebOptions = append(DefaultWebOptions, renderer.WithDebug())
+		}
+
+		if c.Bool("preload") {
+			DefaultWebOptions = append(DefaultWebOptions, renderer.WithPreload())
+		}
+
+		if c.Bool("gzip") {
+			DefaultWebOptions = append(DefaultWebOptions, renderer.WithCompression(c.Int("gzip-min-size")))
+		}
+
+		if keys := c.StringSlice("session-key"); len(keys) != 0 {
+			var store *sessions.Store
+			store, err = constructSessions(keys, c)
+			if err != nil {
+				return fmt.Errorf("[sessions] %v", err)
+			}
+			DefaultWebOptions = append(DefaultWebOptions, renderer.WithSessions(store))
+		}
+
+		// Create a store of cached responses shared by routes
+		responses := renderer.NewCacheStore()
+		DefaultWebOptions = append(DefaultWebOptions, renderer.WithCacheStore(responses))
+
 		// Turn routes into HTTP handler
-		api, err := constructHandler(c.StringSlice("routes"), DefaultWebOptions)
+		api, err := constructHandler(c.StringSlice("routes"), c.String("env"), DefaultWebOptions)
 		if err != nil {
 			return fmt.Errorf("[routes] %v", err)
 		}
@@ -149,13 +225,14 @@ var Web = cli.Command{
 			Options:  DefaultWebOptions,
 			Watching: c.Bool("watch"),
 			Routes:   c.StringSlice("routes"),
+			Env:      c.String("env"),
 			Mutex:    new(sync.RWMutex),
 		}
 
 		if c.Bool("watch") {
 			// Start watching for changes in components directory
 			var w *watcher.Watcher
-			w, err = watcher.Start(c.String("components"), storage)
+			w, err = watcher.Start(c.String("components"), watcher.Flushers{storage, responses})
 			if err != nil {
 				return
 			}
@@ -164,7 +241,7 @@ var Web = cli.Command{
 			// Start watching for changes in routes
 			for _, filename := range c.StringSlice("routes") {
 				var watch *watcher.Watcher
-				watch, err = watcher.Start(filename, handler)
+				watch, err = watcher.Start(filename, watcher.Flushers{handler, responses})
 				if err != nil {
 					return
 				}
@@ -173,7 +250,7 @@ var Web = cli.Command{
 		}
 
 		// Start profiler if enabled
-		if addr := c.String("pprof-addr"); addr != "" {
+		if addr := c.String("debug-addr"); addr != "" {
 			go func() {
 				if err = debugServer(addr); err != nil {
 					glog.Fatal(err)
@@ -203,6 +280,7 @@ type atomicHandler struct {
 	Mutex    *sync.RWMutex
 	Watching bool
 	Routes   []string
+	Env      string
 }
 
 // FlushCache - Flushes routes cache. Reads them and constructs handler.
@@ -220,7 +298,7 @@ func (handler *atomicHandler) FlushCache() {
 }
 
 func (handler *atomicHandler) construct() (_ http.Handler, err error) {
-	h, err := constructHandler(handler.Routes, handler.Options)
+	h, err := constructHandler(handler.Routes, handler.Env, handler.Options)
 	if err != nil {
 		return
 	}
@@ -243,12 +321,12 @@ func (handler *atomicHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 	h.ServeHTTP(w, r)
 }
 
-func constructHandler(filenames []string, options []renderer.Option) (_ xhandler.HandlerC, err error) {
+func constructHandler(filenames []string, env string, options []renderer.Option) (_ xhandler.HandlerC, err error) {
 	if len(filenames) == 0 {
-		return renderer.New(), nil
+		return constructAPI(), nil
 	}
 
-	routes, err := constructRoutes(filenames, options)
+	routes, err := constructRoutes(filenames, env, options)
 	if err != nil {
 		return
 	}
@@ -257,8 +335,19 @@ func constructHandler(filenames []string, options []renderer.Option) (_ xhandler
 	return routes.Construct(options...)
 }
 
+// constructAPI - Constructs API handler rendering components from requests
+// with batch rendering on `POST /batch`.
+func constructAPI() xhandler.HandlerC {
+	mux := xmux.New()
+	mux.HandleMethodNotAllowed = false
+	mux.NotFound = renderer.New()
+	mux.HandleC("POST", "/batch", renderer.NewBatch())
+	return mux
+}
+
 // constructRoutes - Constructs routes map from multiple filenames.
-func constructRoutes(filenames []string, options []renderer.Option) (res renderer.Routes, err error) {
+// Routes files are overridden by environment overlays if they exist.
+func constructRoutes(filenames []string, env string, options []renderer.Option) (res renderer.Routes, err error) {
 	res = make(renderer.Routes)
 	for _, filename := range filenames {
 		var routes renderer.Routes
@@ -266,6 +355,17 @@ func constructRoutes(filenames []string, options []renderer.Option) (res rendere
 		if err != nil {
 			return
 		}
+		if env != "" {
+			overlay := renderer.OverlayFilename(filename, env)
+			if _, err = os.Stat(overlay); err == nil {
+				if err = routes.OverlayFromFile(overlay); err != nil {
+					return
+				}
+			} else if !os.IsNotExist(err) {
+				return
+			}
+			err = nil
+		}
 		for route, handler := range routes {
 			if _, exists := res[route]; exists {
 				return nil, fmt.Errorf("route %q in %q is not unique", route, filename)
@@ -276,6 +376,20 @@ func constructRoutes(filenames []string, options []renderer.Option) (res rendere
 	return
 }
 
+// constructSessions - Constructs sessions store from keys and flags.
+func constructSessions(keys []string, c *cli.Context) (*sessions.Store, error) {
+	var list [][]byte
+	for _, key := range keys {
+		list = append(list, []byte(key))
+	}
+	return sessions.New(list,
+		sessions.WithName(c.String("session-name")),
+		sessions.WithMaxAge(c.Duration("session-max-age")),
+		sessions.WithSecure(c.Bool("session-secure")),
+		sessions.WithEncryption(c.Bool("session-encrypt")),
+	)
+}
+
 func debugServer(addr string) (err error) {
 	glog.Infof("[debug] starting server on %s", addr)
 	return http.ListenAndServe(addr, nil)
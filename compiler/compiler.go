@@ -1,10 +1,15 @@
 package compiler
 
 import (
+	"fmt"
 	"os"
+	"path"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"tower.pro/renderer/components"
+	"tower.pro/renderer/helpers"
 	"tower.pro/renderer/storage"
 	"tower.pro/renderer/template"
 )
@@ -22,8 +27,10 @@ func New(s *storage.Storage) *Compiler {
 // Compile - Compiles a component.
 // Expects the component to have all the required data embed or in storage.
 func (comp *Compiler) Compile(c *components.Component) (compiled *components.Compiled, err error) {
+	start := time.Now()
 	compiled = &components.Compiled{Component: c}
 	err = comp.compileTo(compiled, c)
+	compiled.CompileTime = time.Since(start)
 	return
 }
 
@@ -39,6 +46,8 @@ func (comp *Compiler) CompileByName(name string) (compiled *components.Compiled,
 // CompileFromStorage - Gets component from storage by name and merges
 // with component given in argument.
 func (comp *Compiler) CompileFromStorage(c *components.Component) (compiled *components.Compiled, err error) {
+	start := time.Now()
+
 	// Get component from storage by name
 	component, err := comp.Storage.Component(c.Name)
 	if err != nil {
@@ -54,6 +63,7 @@ func (comp *Compiler) CompileFromStorage(c *components.Component) (compiled *com
 
 	// Overwrite defaults with given component settings
 	err = comp.compileTo(compiled, component)
+	compiled.CompileTime = time.Since(start)
 	return
 }
 
@@ -84,6 +94,9 @@ func (comp *Compiler) compileTo(compiled *components.Compiled, c *components.Com
 		return
 	}
 
+	// Merge assets excluded from preloading
+	compiled.NoPreload = helpers.MergeUnique(compiled.NoPreload, c.NoPreload)
+
 	// Compile `With` templates map and merge into `compiled`
 	if compiled.With != nil && c.With != nil {
 		compiled.With, err = compiled.With.ParseAndMerge(c.With)
@@ -94,12 +107,33 @@ func (comp *Compiler) compileTo(compiled *components.Compiled, c *components.Com
 		return
 	}
 
+	// Compile document head entries
+	if c.Head != nil {
+		compiled.Head, err = compileHead(compiled.Head, c.Head)
+		if err != nil {
+			return
+		}
+	}
+
+	// Update modification time with component files
+	compiled.ModTime = latest(compiled.ModTime, comp.modTime(c, base))
+
 	// Compile a component which this one `extends`
 	if c.Extends != "" {
 		compiled.Extends, err = comp.CompileByName(c.Extends)
 		if err != nil {
 			return
 		}
+		compiled.ModTime = latest(compiled.ModTime, compiled.Extends.ModTime)
+	}
+
+	// Compile a fallback component if not compiled yet
+	if c.Fallback != "" && compiled.Fallback == nil {
+		compiled.Fallback, err = comp.compileFallback(c, base)
+		if err != nil {
+			return
+		}
+		compiled.ModTime = latest(compiled.ModTime, compiled.Fallback.ModTime)
 	}
 
 	// Compile required components
@@ -112,7 +146,75 @@ func (comp *Compiler) compileTo(compiled *components.Compiled, c *components.Com
 			compiled.Require = make(map[string]*components.Compiled)
 		}
 		compiled.Require[name] = req
+		compiled.ModTime = latest(compiled.ModTime, req.ModTime)
 	}
 
 	return
 }
+
+// modTime - Returns latest modification time of component definition
+// and its template files in storage.
+func (comp *Compiler) modTime(c *components.Component, base string) (t time.Time) {
+	t = comp.Storage.ComponentModTime(c.Name)
+	for _, path := range templateFiles(c, base) {
+		t = latest(t, comp.Storage.ModTime(path))
+	}
+	return
+}
+
+// templateFiles - Returns storage paths of component template files.
+func templateFiles(c *components.Component, base string) (paths []string) {
+	texts := append([]string{c.Main, c.Fallback}, c.Styles...)
+	for _, text := range append(texts, c.Scripts...) {
+		if path, ok := filePath(text, base); ok {
+			paths = append(paths, path)
+		}
+	}
+	return
+}
+
+// TemplateFile - Returns true if file by slash-separated storage path
+// is a template of a component defined in its directory or parent directories.
+// Returns true if any of those components definitions cannot be read.
+func (comp *Compiler) TemplateFile(name string) bool {
+	name = path.Clean("/" + name)
+	for dir := path.Dir(name); dir != "/"; dir = path.Dir(dir) {
+		cname := strings.Replace(strings.TrimPrefix(dir, "/"), "/", ".", -1)
+		if comp.Storage.ComponentModTime(cname).IsZero() {
+			continue
+		}
+		c, err := comp.Storage.Component(cname)
+		if err != nil {
+			return true
+		}
+		base := strings.Replace(cname, ".", string(os.PathSeparator), -1)
+		for _, p := range templateFiles(c, base) {
+			if path.Clean("/"+filepath.ToSlash(p)) == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func latest(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
+// compileFallback - Compiles fallback by component name or inline template.
+func (comp *Compiler) compileFallback(c *components.Component, base string) (compiled *components.Compiled, err error) {
+	if _, _, ok := parseScheme(c.Fallback); !ok {
+		return comp.CompileByName(c.Fallback)
+	}
+	main, err := parseTemplate(comp.Storage, c.Fallback, base)
+	if err != nil {
+		return nil, fmt.Errorf("fallback: %v", err)
+	}
+	return &components.Compiled{
+		Component: &components.Component{Name: c.Name, Main: c.Fallback},
+		Main:      main,
+	}, nil
+}
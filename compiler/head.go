@@ -0,0 +1,94 @@
+package compiler
+
+import (
+	"fmt"
+
+	"tower.pro/renderer/components"
+	"tower.pro/renderer/template"
+)
+
+// compileHead - Compiles head entries templates and merges into `compiled`.
+// Entries already compiled are not overwritten.
+func compileHead(compiled *components.CompiledHead, head *components.Head) (_ *components.CompiledHead, err error) {
+	if compiled == nil {
+		compiled = new(components.CompiledHead)
+	}
+	if compiled.Title == nil && head.Title != "" {
+		compiled.Title, err = template.FromString(head.Title)
+		if err != nil {
+			return nil, fmt.Errorf("head title: %v", err)
+		}
+	}
+	compiled.Meta, err = compileEntries(compiled.Meta, head.Meta)
+	if err != nil {
+		return
+	}
+	compiled.Links, err = compileEntries(compiled.Links, head.Links)
+	if err != nil {
+		return
+	}
+	compiled.JSONLD, err = compileJSONLD(compiled.JSONLD, head.JSONLD)
+	return compiled, err
+}
+
+// compileJSONLD - Compiles JSON-LD documents templates and merges into `compiled`.
+// Strings in documents are executed without HTML escaping, documents are encoded as JSON.
+func compileJSONLD(compiled template.Map, docs map[string]interface{}) (_ template.Map, err error) {
+	for key, doc := range docs {
+		if _, has := compiled[key]; has {
+			continue
+		}
+		if compiled == nil {
+			compiled = make(template.Map, len(docs))
+		}
+		var nodes template.Map
+		if nodes, err = template.ParseMap(template.Context{key: rawTemplates(doc)}); err != nil {
+			return nil, fmt.Errorf("head jsonld %q: %v", key, err)
+		}
+		compiled[key] = nodes[key]
+	}
+	return compiled, nil
+}
+
+// rawTemplates - Turns off escaping of strings in a document.
+func rawTemplates(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return "{% autoescape off %}" + v + "{% endautoescape %}"
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			res[key] = rawTemplates(value)
+		}
+		return res
+	case map[interface{}]interface{}:
+		res := make(map[interface{}]interface{}, len(v))
+		for key, value := range v {
+			res[key] = rawTemplates(value)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for index, value := range v {
+			res[index] = rawTemplates(value)
+		}
+		return res
+	}
+	return value
+}
+
+func compileEntries(compiled map[string]template.Template, entries map[string]string) (_ map[string]template.Template, err error) {
+	for key, text := range entries {
+		if _, has := compiled[key]; has {
+			continue
+		}
+		if compiled == nil {
+			compiled = make(map[string]template.Template, len(entries))
+		}
+		compiled[key], err = template.FromString(text)
+		if err != nil {
+			return nil, fmt.Errorf("head %q: %v", key, err)
+		}
+	}
+	return compiled, nil
+}
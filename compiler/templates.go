@@ -62,6 +62,15 @@ func parseTemplates(s *storage.Storage, texts []string, baseDir string, start []
 	return
 }
 
+// filePath - Returns storage path of a template if it's a file.
+func filePath(text, baseDir string) (path string, ok bool) {
+	scheme, rest, ok := parseScheme(text)
+	if !ok || (scheme != "file" && scheme != "file+text") {
+		return "", false
+	}
+	return filepath.Join(baseDir, rest), true
+}
+
 func parseScheme(text string) (scheme, rest string, ok bool) {
 	if strings.HasPrefix(text, "/") {
 		return "http", text, true
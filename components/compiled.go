@@ -1,6 +1,16 @@
 package components
 
-import "tower.pro/renderer/template"
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"tower.pro/renderer/template"
+)
 
 // Compiled - Compiled component ready to render.
 type Compiled struct {
@@ -25,6 +35,52 @@ type Compiled struct {
 	// Scripts - Compiled scripts templates.
 	Scripts []template.Template
 
+	// NoPreload - List of styles and scripts URLs excluded from preloading.
+	NoPreload []string
+
 	// Require - Compiled `Require` components.
 	Require map[string]*Compiled
+
+	// Fallback - Compiled `Fallback` component.
+	Fallback *Compiled
+
+	// Head - Compiled document head entries.
+	Head *CompiledHead
+
+	// CompileTime - Duration of component compilation.
+	CompileTime time.Duration
+
+	// ModTime - Latest modification time of files of the components tree in storage.
+	// Zero if none of the components comes from storage.
+	ModTime time.Time
+}
+
+// Hash - Returns hash of the compiled components tree.
+// It's computed from source components and modification time of their files.
+func (c *Compiled) Hash() string {
+	h := fnv.New64a()
+	c.hash(h)
+	return strconv.FormatUint(h.Sum64(), 36)
+}
+
+func (c *Compiled) hash(w io.Writer) {
+	json.NewEncoder(w).Encode(c.Component)
+	fmt.Fprintf(w, "%d\n", c.ModTime.UnixNano())
+	if c.Extends != nil {
+		io.WriteString(w, "extends\n")
+		c.Extends.hash(w)
+	}
+	if c.Fallback != nil {
+		io.WriteString(w, "fallback\n")
+		c.Fallback.hash(w)
+	}
+	names := make([]string, 0, len(c.Require))
+	for name := range c.Require {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "require %s\n", name)
+		c.Require[name].hash(w)
+	}
 }
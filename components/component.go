@@ -22,13 +22,29 @@ type Component struct {
 	// When local files will be read and parsed as templates.
 	Scripts []string `json:"scripts,omitempty" yaml:"scripts,omitempty"`
 
+	// NoPreload - List of styles and scripts URLs which should not be preloaded.
+	NoPreload []string `json:"no_preload,omitempty" yaml:"no_preload,omitempty"`
+
 	// Require - Components required by this component.
 	// Those will be rendered before and set in context under keys from map.
 	Require map[string]Component `json:"require,omitempty" yaml:"require,omitempty"`
 
+	// Fallback - Component name or inline template (eq. `template://...`)
+	// rendered in place of this component when it fails to render.
+	Fallback string `json:"fallback,omitempty" yaml:"fallback,omitempty"`
+
+	// Defer - Renders required component in background when streaming.
+	// Placeholder is rendered in its place and replaced when component is streamed
+	// after the body of the page. Ignored when not streaming.
+	Defer bool `json:"defer,omitempty" yaml:"defer,omitempty"`
+
 	// Context - Base context for the component.
 	Context template.Context `json:"context,omitempty" yaml:"context,omitempty"`
 
 	// With - Like context but values should be templates.
 	With template.Context `json:"with,omitempty" yaml:"with,omitempty"`
+
+	// Head - Document head entries declared by the component.
+	// Values are templates, rendered entries are inserted into document `<head>`.
+	Head *Head `json:"head,omitempty" yaml:"head,omitempty"`
 }
@@ -0,0 +1,96 @@
+package components
+
+import (
+	"fmt"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+
+	"tower.pro/renderer/helpers"
+	"tower.pro/renderer/template"
+)
+
+// deferredScript - Defines function replacing placeholder with deferred component body.
+const deferredScript = `<script>function rendererSwap(id){var p=document.getElementById(id),t=document.getElementById(id+"-content");if(p&&t){p.parentNode.replaceChild(t.content,p);t.parentNode.removeChild(t)}}</script>`
+
+// deferredQueue - Required components deferred when streaming.
+type deferredQueue struct {
+	ctx  context.Context
+	list []*deferred
+}
+
+// deferred - Required component rendered in background
+// and streamed after the body of the page.
+type deferred struct {
+	id   string
+	name string
+	c    *Compiled
+	done chan struct{}
+
+	// main - where assets of component are inserted
+	main *Rendered
+	res  *Rendered
+	err  error
+}
+
+// add - Starts rendering of a component in background with a copy of context.
+// Returns placeholder HTML which is replaced when component is streamed.
+func (q *deferredQueue) add(name string, c *Compiled, ctx template.Context) string {
+	d := &deferred{
+		id:   fmt.Sprintf("renderer-deferred-%d", len(q.list)+1),
+		name: name,
+		c:    c,
+		done: make(chan struct{}),
+	}
+	q.list = append(q.list, d)
+	go d.render(q.ctx, ctx.Clone())
+	return fmt.Sprintf(`<div id="%s" class="renderer-deferred"></div>`, d.id)
+}
+
+// ready - Returns channel receiving deferred components in order
+// they are rendered. It receives exactly one value for each component.
+func (q *deferredQueue) ready() <-chan *deferred {
+	ready := make(chan *deferred, len(q.list))
+	for _, d := range q.list {
+		go func(d *deferred) {
+			<-d.done
+			ready <- d
+		}(d)
+	}
+	return ready
+}
+
+// render - Renders component unless request context is already done.
+func (d *deferred) render(reqCtx context.Context, ctx template.Context) {
+	defer close(d.done)
+	d.main = new(Rendered)
+	d.res = new(Rendered)
+	if d.err = reqCtx.Err(); d.err != nil {
+		return
+	}
+	d.err = renderComponent(d.c, d.main, d.res, ctx, node{})
+	if d.err != nil && d.c.Fallback != nil {
+		d.res, d.err = renderFallback(d.name, d.c, d.main, ctx, d.err, node{})
+	}
+}
+
+// merge - Merges assets of rendered component into `main`.
+// Returns HTML chunk replacing placeholder or empty string on error.
+func (d *deferred) merge(main *Rendered) string {
+	main.Errors = append(main.Errors, d.main.Errors...)
+	if d.err != nil {
+		glog.Warningf("[render] deferred require %q (%s) failed: %v", d.name, d.c.Name, d.err)
+		main.Errors = append(main.Errors, fmt.Sprintf("%s (%s): %v", d.name, d.c.Name, d.err))
+		return ""
+	}
+	main.Styles = helpers.MergeUnique(main.Styles, d.main.Styles)
+	main.Scripts = helpers.MergeUnique(main.Scripts, d.main.Scripts)
+	main.NoPreload = helpers.MergeUnique(main.NoPreload, d.main.NoPreload)
+	if d.main.Head != nil {
+		if main.Head == nil {
+			main.Head = new(Head)
+		}
+		main.Head.Merge(d.main.Head)
+	}
+	return fmt.Sprintf(`<template id="%s-content">%s</template><script>rendererSwap(%q)</script>`, d.id, d.res.Body, d.id)
+}
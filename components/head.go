@@ -0,0 +1,181 @@
+package components
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+
+	"github.com/golang/glog"
+
+	"tower.pro/renderer/template"
+)
+
+// Head - Document head entries.
+// In component definition values are templates executed with component context.
+// Entries are deduplicated by key. Components are rendered from the innermost,
+// so when two components declare the same entry, the outermost one wins.
+type Head struct {
+	// Title - Document title.
+	Title string `json:"title,omitempty" yaml:"title,omitempty"`
+
+	// Meta - Meta tags content by name or property (eq. `description`, `og:title`).
+	Meta map[string]string `json:"meta,omitempty" yaml:"meta,omitempty"`
+
+	// Links - Link tags href by relation (eq. `canonical`).
+	Links map[string]string `json:"links,omitempty" yaml:"links,omitempty"`
+
+	// JSONLD - JSON-LD documents by key. Strings in documents are templates
+	// executed without HTML escaping, documents are encoded as JSON.
+	JSONLD map[string]interface{} `json:"jsonld,omitempty" yaml:"jsonld,omitempty"`
+}
+
+// CompiledHead - Compiled document head entries templates.
+type CompiledHead struct {
+	// Title - Document title template.
+	Title template.Template
+
+	// Meta - Meta tags content templates.
+	Meta map[string]template.Template
+
+	// Links - Link tags href templates.
+	Links map[string]template.Template
+
+	// JSONLD - JSON-LD documents templates.
+	JSONLD template.Map
+}
+
+// IsEmpty - Returns true if head has no entries.
+func (head *Head) IsEmpty() bool {
+	return head == nil || (head.Title == "" && len(head.Meta) == 0 && len(head.Links) == 0 && len(head.JSONLD) == 0)
+}
+
+// Merge - Merges entries from `other` head overwriting existing ones.
+func (head *Head) Merge(other *Head) {
+	if other.Title != "" {
+		head.Title = other.Title
+	}
+	head.Meta = mergeEntries(head.Meta, other.Meta)
+	head.Links = mergeEntries(head.Links, other.Links)
+	if len(other.JSONLD) != 0 && head.JSONLD == nil {
+		head.JSONLD = make(map[string]interface{}, len(other.JSONLD))
+	}
+	for key, doc := range other.JSONLD {
+		head.JSONLD[key] = doc
+	}
+}
+
+// HTML - Returns head entries as HTML tags.
+// Values are written as they are, template variables are escaped on execution.
+// JSON-LD documents are encoded as JSON with `<` and `>` escaped.
+func (head *Head) HTML() (res []string) {
+	if head.Title != "" {
+		res = append(res, fmt.Sprintf("<title>%s</title>", head.Title))
+	}
+	for _, key := range sortedKeys(head.Meta) {
+		res = append(res, fmt.Sprintf(`<meta %s="%s" content="%s" />`, metaAttr(key), html.EscapeString(key), head.Meta[key]))
+	}
+	for _, rel := range sortedKeys(head.Links) {
+		res = append(res, fmt.Sprintf(`<link rel="%s" href="%s" />`, html.EscapeString(rel), head.Links[rel]))
+	}
+	keys := make([]string, 0, len(head.JSONLD))
+	for key := range head.JSONLD {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		body, err := json.Marshal(head.JSONLD[key])
+		if err != nil {
+			glog.Warningf("[render] head jsonld %q encode error: %v", key, err)
+			continue
+		}
+		res = append(res, fmt.Sprintf(`<script type="application/ld+json">%s</script>`, body))
+	}
+	return
+}
+
+// Execute - Executes head templates with context.
+func (head *CompiledHead) Execute(ctx template.Context) (res *Head, err error) {
+	res = new(Head)
+	if head.Title != nil {
+		res.Title, err = template.ExecuteToString(head.Title, ctx)
+		if err != nil {
+			return
+		}
+	}
+	res.Meta, err = executeEntries(head.Meta, ctx)
+	if err != nil {
+		return
+	}
+	res.Links, err = executeEntries(head.Links, ctx)
+	if err != nil {
+		return
+	}
+	if len(head.JSONLD) != 0 {
+		res.JSONLD, err = head.JSONLD.Execute(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("head jsonld: %v", err)
+		}
+	}
+	return
+}
+
+// renderHead - Renders component head entries into `res`.
+func renderHead(c *Compiled, res *Rendered, ctx template.Context) (err error) {
+	if c.Head == nil {
+		return
+	}
+	head, err := c.Head.Execute(ctx)
+	if err != nil {
+		return
+	}
+	if res.Head == nil {
+		res.Head = new(Head)
+	}
+	res.Head.Merge(head)
+	return
+}
+
+func executeEntries(entries map[string]template.Template, ctx template.Context) (res map[string]string, err error) {
+	if len(entries) == 0 {
+		return
+	}
+	res = make(map[string]string, len(entries))
+	for key, t := range entries {
+		res[key], err = template.ExecuteToString(t, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("head %q: %v", key, err)
+		}
+	}
+	return
+}
+
+func mergeEntries(dest, source map[string]string) map[string]string {
+	if len(source) == 0 {
+		return dest
+	}
+	if dest == nil {
+		dest = make(map[string]string, len(source))
+	}
+	for key, value := range source {
+		dest[key] = value
+	}
+	return dest
+}
+
+// metaAttr - Returns `property` for Open Graph like keys and `name` otherwise.
+func metaAttr(key string) string {
+	if strings.Contains(key, ":") && !strings.HasPrefix(key, "twitter:") {
+		return "property"
+	}
+	return "name"
+}
+
+func sortedKeys(m map[string]string) (keys []string) {
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return
+}
@@ -0,0 +1,114 @@
+package components
+
+import (
+	"strings"
+	"testing"
+
+	"tower.pro/renderer/template"
+)
+
+func mustTemplate(t *testing.T, text string) template.Template {
+	tmp, err := template.FromString(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tmp
+}
+
+func TestRenderHead(t *testing.T) {
+	layout := &Compiled{
+		Component: &Component{Name: "site.root"},
+		Main:      mustTemplate(t, "<html><head><title>Site</title></head><body>{{ children }}</body></html>"),
+		Head: &CompiledHead{
+			Meta: map[string]template.Template{
+				"og:title":    mustTemplate(t, "Site"),
+				"description": mustTemplate(t, "Site description"),
+			},
+		},
+	}
+	video := &Compiled{
+		Component: &Component{Name: "movies.video"},
+		Main:      mustTemplate(t, "<video></video>"),
+		Head: &CompiledHead{
+			Meta: map[string]template.Template{
+				"og:title": mustTemplate(t, "Video"),
+				"og:video": mustTemplate(t, "/{{ movie }}.mp4"),
+			},
+		},
+	}
+	movie := &Compiled{
+		Component: &Component{Name: "movies.movie"},
+		Main:      mustTemplate(t, "{{ video }}"),
+		Extends:   layout,
+		Require:   map[string]*Compiled{"video": video},
+		Head: &CompiledHead{
+			Title: mustTemplate(t, "Movie {{ movie }}"),
+			Meta: map[string]template.Template{
+				"og:title": mustTemplate(t, "Movie {{ movie }}"),
+			},
+		},
+	}
+
+	res, err := Render(movie, template.Context{"movie": "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"og:title":    "Movie test",
+		"og:video":    "/test.mp4",
+		"description": "Site description",
+	}
+	for key, value := range expected {
+		if res.Head.Meta[key] != value {
+			t.Errorf("Invalid meta %q value: %q", key, res.Head.Meta[key])
+		}
+	}
+
+	html := res.HTML()
+	if strings.Count(html, "<title>") != 1 || !strings.Contains(html, "<title>Movie test</title>") {
+		t.Errorf("Invalid title in: %s", html)
+	}
+	if !strings.Contains(html, `<meta property="og:video" content="/test.mp4" /></head>`) {
+		t.Errorf("Meta not inserted in head: %s", html)
+	}
+}
+
+func TestHeadHTMLEscaping(t *testing.T) {
+	jsonld, err := template.ParseMap(template.Context{
+		"movie": map[string]interface{}{
+			"@type": "Movie",
+			"name":  "{% autoescape off %}{{ name }}{% endautoescape %}",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := &Compiled{
+		Component: &Component{Name: "movies.movie"},
+		Main:      mustTemplate(t, `<html><head></head><body><svg><title>icon</title></svg></body></html>`),
+		Head: &CompiledHead{
+			Title:  mustTemplate(t, "{{ name }}"),
+			Meta:   map[string]template.Template{`x" onload="y`: template.Text("z")},
+			Links:  map[string]template.Template{`a"b`: template.Text("/c")},
+			JSONLD: jsonld,
+		},
+	}
+
+	res, err := Render(page, template.Context{"name": `Tom & "Jerry"</script>`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := res.HTML()
+	for _, expected := range []string{
+		`<script type="application/ld+json">{"@type":"Movie","name":"Tom \u0026 \"Jerry\"\u003c/script\u003e"}</script>`,
+		`<meta name="x&#34; onload=&#34;y" content="z" />`,
+		`<link rel="a&#34;b" href="/c" />`,
+		`<head><title>Tom &amp; &quot;Jerry&quot;&lt;/script&gt;</title>`,
+		`<svg><title>icon</title></svg>`,
+	} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("Expected %s in: %s", expected, html)
+		}
+	}
+}
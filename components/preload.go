@@ -0,0 +1,42 @@
+package components
+
+import (
+	"fmt"
+
+	"tower.pro/renderer/helpers"
+)
+
+// Preload - Asset preload hint.
+type Preload struct {
+	// URL - Asset URL.
+	URL string
+
+	// As - Asset type (`style` or `script`).
+	As string
+}
+
+// Preloads - Returns preload hints for URL styles and scripts.
+// Assets listed in `NoPreload` are omitted.
+func (r *Rendered) Preloads() (res []Preload) {
+	for _, src := range r.URLStyles() {
+		if !helpers.Contain(r.NoPreload, src) {
+			res = append(res, Preload{URL: src, As: "style"})
+		}
+	}
+	for _, src := range r.URLScripts() {
+		if !helpers.Contain(r.NoPreload, src) {
+			res = append(res, Preload{URL: src, As: "script"})
+		}
+	}
+	return
+}
+
+// Header - Returns preload hint as `Link` header value.
+func (p Preload) Header() string {
+	return fmt.Sprintf("<%s>; rel=preload; as=%s", p.URL, p.As)
+}
+
+// HTML - Returns preload hint as HTML link tag.
+func (p Preload) HTML() string {
+	return fmt.Sprintf(`<link rel="preload" href="%s" as="%s" />`, p.URL, p.As)
+}
@@ -0,0 +1,45 @@
+package components
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPreloads(t *testing.T) {
+	res := &Rendered{
+		Body:      "<html><head></head><body></body></html>",
+		Styles:    []string{"/main.css", "/print.css", "p{color:red}"},
+		Scripts:   []string{"https://cdn.example.com/app.js", "run()"},
+		NoPreload: []string{"/print.css"},
+	}
+	expected := []Preload{
+		{URL: "/main.css", As: "style"},
+		{URL: "https://cdn.example.com/app.js", As: "script"},
+	}
+	preloads := res.Preloads()
+	if !reflect.DeepEqual(preloads, expected) {
+		t.Fatalf("Invalid preloads: %v", preloads)
+	}
+	if h := preloads[0].Header(); h != "</main.css>; rel=preload; as=style" {
+		t.Errorf("Invalid preload header: %q", h)
+	}
+
+	html := res.PreloadHTML()
+	head := html[:strings.Index(html, "</head>")]
+	for _, tag := range []string{
+		`<link rel="preload" href="/main.css" as="style" />`,
+		`<link rel="preload" href="https://cdn.example.com/app.js" as="script" />`,
+		`<link rel="stylesheet" href="/print.css" />`,
+	} {
+		if !strings.Contains(head, tag) {
+			t.Errorf("Expected %q in head: %s", tag, html)
+		}
+	}
+	if strings.Contains(html, `href="/print.css" as="style"`) {
+		t.Errorf("Excluded style preloaded: %s", html)
+	}
+	if strings.Contains(res.HTML(), `rel="preload"`) {
+		t.Errorf("Preload without preloading: %s", res.HTML())
+	}
+}
@@ -1,6 +1,9 @@
 package components
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/flosch/pongo2"
 	"github.com/golang/glog"
 
@@ -8,40 +11,112 @@ import (
 	"tower.pro/renderer/template"
 )
 
+// RenderOption - Component render option.
+type RenderOption func(*Compiled, *Rendered)
+
+// WithTrace - Records render trace tree in `Trace` of the result.
+func WithTrace() RenderOption {
+	return func(c *Compiled, res *Rendered) {
+		res.Trace = newTrace("", c)
+	}
+}
+
+// WithTree - Records rendered components tree in `Tree` of the result.
+func WithTree() RenderOption {
+	return func(c *Compiled, res *Rendered) {
+		res.Tree = &Tree{Name: c.Name}
+	}
+}
+
 // Render - Renders compiled component.
 // Only first template context is accepted.
 // Sets source component in template context under key `source_component`.
-func Render(c *Compiled, ctxs ...template.Context) (res *Rendered, err error) {
+func Render(c *Compiled, ctxs ...template.Context) (*Rendered, error) {
 	var ctx template.Context
-	if len(ctxs) == 0 || ctxs[0] == nil {
-		ctx = make(template.Context)
-	} else {
+	if len(ctxs) != 0 {
 		ctx = ctxs[0]
 	}
+	return RenderWith(c, ctx)
+}
+
+// RenderWith - Renders compiled component with options.
+// Sets source component in template context under key `source_component`.
+func RenderWith(c *Compiled, ctx template.Context, opts ...RenderOption) (res *Rendered, err error) {
+	if ctx == nil {
+		ctx = make(template.Context)
+	}
 	ctx["source_component"] = c.Component
 	res = new(Rendered)
-	err = renderComponent(c, res, res, ctx)
+	for _, opt := range opts {
+		opt(c, res)
+	}
+	err = renderComponent(c, res, res, ctx, node{trace: res.Trace, tree: res.Tree})
 	return
 }
 
 // renderComponent - Renders a component.
 // `main` is where `Styles` and `Scripts` are inserted.
 // `res` is where `Body` is inserted.
-func renderComponent(c *Compiled, main, res *Rendered, ctx template.Context) (err error) {
+// `n` is where trace and tree of the component are recorded if enabled.
+func renderComponent(c *Compiled, main, res *Rendered, ctx template.Context, n node) (err error) {
+	if n.trace != nil {
+		start := time.Now()
+		defer func() { n.trace.Render = time.Since(start) }()
+	}
+
 	// Set component defaults
 	ctx, err = withComponentDefaults(c, ctx)
 	if err != nil {
 		return
 	}
 
+	// Render required components and `Main` template
+	err = renderBody(c, main, res, ctx, n)
+	if err != nil {
+		return
+	}
+
+	// Extend a template if any
+	if c.Extends != nil {
+		if res.Body != "" {
+			ctx["children"] = pongo2.AsSafeValue(res.Body)
+		}
+		err = renderComponent(c.Extends, main, res, ctx, n.child("extends", c.Extends))
+		if err != nil {
+			return
+		}
+	}
+
+	// Render component styles and scripts
+	err = renderAssets(c, main, ctx, n)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// renderBody - Renders required components and component `Main` template.
+// Expects component defaults to be already set in context.
+func renderBody(c *Compiled, main, res *Rendered, ctx template.Context, n node) (err error) {
 	if glog.V(11) {
 		glog.Infof("[render] name=%q ctx=%#v", c.Name, ctx)
 	}
 
 	// Render required components
 	for name, req := range c.Require {
+		// Put a placeholder in place of deferred component when streaming
+		if req.Defer && main.deferred != nil {
+			ctx[name] = pongo2.AsSafeValue(main.deferred.add(name, req, ctx))
+			continue
+		}
+
 		r := new(Rendered)
-		err = renderComponent(req, main, r, ctx)
+		child := n.child(name, req)
+		err = renderComponent(req, main, r, ctx, child)
+		if err != nil && req.Fallback != nil {
+			r, err = renderFallback(name, req, main, ctx, err, child)
+		}
 		if err != nil {
 			return
 		}
@@ -57,45 +132,45 @@ func renderComponent(c *Compiled, main, res *Rendered, ctx template.Context) (er
 			return
 		}
 	}
+	n.setBody(res.Body, ctx)
+	return
+}
 
-	// Extend a template if any
-	if c.Extends != nil {
-		if res.Body != "" {
-			ctx["children"] = pongo2.AsSafeValue(res.Body)
-		}
-		err = renderComponent(c.Extends, main, res, ctx)
-		if err != nil {
-			return
-		}
-	}
-
-	// Render component styles and scripts
-	err = renderAssets(c, main, ctx)
-	if err != nil {
-		return
-	}
-
+// renderFallback - Renders fallback of a required component which failed with `failure`.
+// Failure is logged and saved in `main` errors.
+func renderFallback(name string, c *Compiled, main *Rendered, ctx template.Context, failure error, n node) (res *Rendered, err error) {
+	glog.Warningf("[render] require %q (%s) failed, rendering fallback: %v", name, c.Name, failure)
+	main.Errors = append(main.Errors, fmt.Sprintf("%s (%s): %v", name, c.Name, failure))
+	res = new(Rendered)
+	err = renderComponent(c.Fallback, main, res, ctx, n.fallback(c.Fallback))
 	return
 }
 
-func renderAssets(c *Compiled, res *Rendered, ctx template.Context) (err error) {
+func renderAssets(c *Compiled, res *Rendered, ctx template.Context, n node) (err error) {
 	// Render component styles
-	tmp, err := template.ExecuteList(c.Styles, ctx)
+	styles, err := template.ExecuteList(c.Styles, ctx)
 	if err != nil {
 		return
 	}
 
 	// Merge component scripts into result
-	res.Styles = helpers.MergeUnique(res.Styles, tmp)
+	res.Styles = helpers.MergeUnique(res.Styles, styles)
 
 	// Render component scripts
-	tmp, err = template.ExecuteList(c.Scripts, ctx)
+	scripts, err := template.ExecuteList(c.Scripts, ctx)
 	if err != nil {
 		return
 	}
 
 	// Merge component scripts into result
-	res.Scripts = helpers.MergeUnique(res.Scripts, tmp)
+	res.Scripts = helpers.MergeUnique(res.Scripts, scripts)
+	n.setAssets(styles, scripts)
+
+	// Merge assets excluded from preloading
+	res.NoPreload = helpers.MergeUnique(res.NoPreload, c.NoPreload)
+
+	// Render component head entries
+	err = renderHead(c, res, ctx)
 	return
 }
 
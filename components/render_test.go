@@ -0,0 +1,119 @@
+package components
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"tower.pro/renderer/helpers"
+	"tower.pro/renderer/template"
+)
+
+func TestRenderFallback(t *testing.T) {
+	broken := &Compiled{
+		Component: &Component{Name: "dashboard.widget"},
+		Main:      mustTemplate(t, "{{ widget|bool }}"),
+		Fallback: &Compiled{
+			Component: &Component{Name: "dashboard.widget"},
+			Main:      mustTemplate(t, "unavailable"),
+		},
+	}
+	page := &Compiled{
+		Component: &Component{Name: "dashboard.page"},
+		Main:      mustTemplate(t, "<div>{{ widget_component }}</div>"),
+		Require:   map[string]*Compiled{"widget_component": broken},
+	}
+
+	res, err := Render(page, template.Context{"widget": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Body != "<div>unavailable</div>" {
+		t.Errorf("Unexpected body: %q", res.Body)
+	}
+	if len(res.Errors) != 1 {
+		t.Errorf("Expected one error, got: %v", res.Errors)
+	}
+
+	broken.Fallback = nil
+	if _, err = Render(page, template.Context{"widget": 1}); err == nil {
+		t.Error("Expected render error without fallback")
+	}
+}
+
+func TestRenderWithTree(t *testing.T) {
+	layout := &Compiled{
+		Component: &Component{Name: "site.root"},
+		Main:      mustTemplate(t, "<body>{{ children }}</body>"),
+	}
+	card := &Compiled{
+		Component: &Component{Name: "site.card"},
+		Main:      mustTemplate(t, "<div>{{ title }}</div>"),
+		Styles:    []template.Template{template.Text("/card.css")},
+	}
+	page := &Compiled{
+		Component: &Component{Name: "site.page"},
+		Main:      mustTemplate(t, "{{ card }}"),
+		Extends:   layout,
+		Require:   map[string]*Compiled{"card": card},
+	}
+
+	res, err := RenderWith(page, template.Context{"title": "test"}, WithTree(), WithTrace())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Tree.Body != "<div>test</div>" || res.Tree.Extends.Body != "<body><div>test</div></body>" {
+		t.Errorf("Unexpected tree bodies: %#v", res.Tree)
+	}
+	tree := res.Tree.Require["card"]
+	if tree == nil || tree.Name != "site.card" || len(tree.Styles) != 1 {
+		t.Fatalf("Unexpected require tree: %#v", tree)
+	}
+	if !helpers.Contain(tree.Context, "title") {
+		t.Errorf("Expected context keys to contain title: %v", tree.Context)
+	}
+	if len(res.Trace.Children) != 2 {
+		t.Errorf("Expected two children in trace: %s", res.Trace)
+	}
+}
+
+func TestRenderWithTrace(t *testing.T) {
+	layout := &Compiled{
+		Component:   &Component{Name: "site.root"},
+		Main:        mustTemplate(t, "<body>{{ children }}</body>"),
+		CompileTime: time.Millisecond,
+	}
+	card := &Compiled{
+		Component: &Component{Name: "site.card"},
+		Main:      mustTemplate(t, "<div>{{ title }}</div>"),
+	}
+	page := &Compiled{
+		Component:   &Component{Name: "site.page"},
+		Main:        mustTemplate(t, "{{ card }}"),
+		Extends:     layout,
+		Require:     map[string]*Compiled{"card": card},
+		CompileTime: 2 * time.Millisecond,
+	}
+
+	res, err := RenderWith(page, template.Context{"title": "test"}, WithTrace())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := res.Trace
+	if tr.Name != "site.page" || tr.Compile != 2*time.Millisecond || tr.Render <= 0 || tr.Size != len("<div>test</div>") {
+		t.Fatalf("Unexpected trace: %#v", tr)
+	}
+	children := make(map[string]*Trace)
+	for _, child := range tr.Children {
+		children[child.Key] = child
+	}
+	if c := children["card"]; c == nil || c.Name != "site.card" || c.Size != len("<div>test</div>") || c.Render > tr.Render {
+		t.Errorf("Unexpected require trace: %s", tr)
+	}
+	if c := children["extends"]; c == nil || c.Name != "site.root" || c.Compile != time.Millisecond || c.Size != len("<body><div>test</div></body>") {
+		t.Errorf("Unexpected extends trace: %s", tr)
+	}
+	if s := tr.String(); !strings.HasPrefix(s, "site.page compile=2ms") || !strings.Contains(s, "\n  card: site.card compile=0s") {
+		t.Errorf("Unexpected trace string: %s", s)
+	}
+}
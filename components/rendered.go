@@ -17,16 +17,129 @@ type Rendered struct {
 	// Scripts - List of scripts.
 	// They can be urls or list of js scripts with prefix "data:text/javascript;".
 	Scripts []string `json:"scripts,omitempty" yaml:"scripts,omitempty"`
+
+	// Head - Document head entries collected from rendered components.
+	Head *Head `json:"head,omitempty" yaml:"head,omitempty"`
+
+	// Trace - Render trace tree. Set only when rendered with trace.
+	Trace *Trace `json:"trace,omitempty" yaml:"trace,omitempty"`
+
+	// Tree - Rendered components tree. Set only when rendered with tree.
+	Tree *Tree `json:"tree,omitempty" yaml:"tree,omitempty"`
+
+	// NoPreload - List of styles and scripts URLs excluded from preloading.
+	NoPreload []string `json:"-" yaml:"-"`
+
+	// Errors - Errors of required components replaced with fallback.
+	Errors []string `json:"-" yaml:"-"`
+
+	// deferred - Components deferred when streaming.
+	// Nil if components are not deferred.
+	deferred *deferredQueue
+}
+
+// HTML - Merges head entries, styles and scripts into HTML body.
+func (r *Rendered) HTML() string {
+	return r.html(false)
+}
+
+// PreloadHTML - Like `HTML` but also inserts preload link tags
+// for URL styles and scripts into HTML head.
+func (r *Rendered) PreloadHTML() string {
+	return r.html(true)
 }
 
-// HTML - Merges styles and scripts into HTML body.
-func (r *Rendered) HTML() (html string) {
-	// Return if no styles or scripts to add.
-	if len(r.Styles) == 0 && len(r.Scripts) == 0 {
+func (r *Rendered) html(preload bool) (html string) {
+	// Return if no head entries, styles or scripts to add.
+	if len(r.Styles) == 0 && len(r.Scripts) == 0 && r.Head.IsEmpty() {
 		return r.Body
 	}
-	html = insertExtras(r.Body, renderList(renderStyle, r.Styles))
-	html, _ = insertBefore(html, "</html>", renderList(renderScript, r.Scripts))
+	var extras []string
+	if preload {
+		for _, p := range r.Preloads() {
+			extras = append(extras, p.HTML())
+		}
+	}
+	extras = append(extras, renderList(renderStyle, r.Styles)...)
+	html = insertExtras(r.headHTML(), extras)
+	scripts := renderList(renderScript, r.Scripts)
+	if res, ok := insertBefore(html, "</html>", scripts); ok {
+		return res
+	}
+	return html + strings.Join(scripts, "")
+}
+
+// headHTML - Returns body with head entries inserted.
+// Title already present in body is replaced when head has a title.
+func (r *Rendered) headHTML() (html string) {
+	if r.Head.IsEmpty() {
+		return r.Body
+	}
+	html = r.Body
+	tags := r.Head.HTML()
+	if r.Head.Title != "" {
+		if res, ok := replaceTitle(html, tags[0]); ok {
+			html, tags = res, tags[1:]
+		}
+	}
+	if len(tags) == 0 {
+		return
+	}
+	return insertExtras(html, tags)
+}
+
+// replaceTitle - Replaces title in document head, titles in body
+// (eq. in inline SVG) are not replaced.
+func replaceTitle(html, title string) (_ string, ok bool) {
+	head := strings.Index(html, "</head>")
+	if head == -1 {
+		return
+	}
+	start := strings.Index(html[:head], "<title>")
+	if start == -1 {
+		return
+	}
+	end := strings.Index(html[start:head], "</title>")
+	if end == -1 {
+		return
+	}
+	end = start + end + len("</title>")
+	return strings.Join([]string{html[:start], title, html[end:]}, ""), true
+}
+
+// Fragment - Returns body with inline styles and scripts without document shell.
+// URL styles and scripts are omitted, they are returned by `URLStyles` and `URLScripts`.
+func (r *Rendered) Fragment() string {
+	var styles, scripts []string
+	for _, src := range r.Styles {
+		if !hasURLPrefix(src) {
+			styles = append(styles, renderStyle(src))
+		}
+	}
+	for _, src := range r.Scripts {
+		if !hasURLPrefix(src) {
+			scripts = append(scripts, renderScript(src))
+		}
+	}
+	return strings.Join(styles, "") + r.Body + strings.Join(scripts, "")
+}
+
+// URLStyles - Returns list of URL styles.
+func (r *Rendered) URLStyles() []string {
+	return filterURLs(r.Styles)
+}
+
+// URLScripts - Returns list of URL scripts.
+func (r *Rendered) URLScripts() []string {
+	return filterURLs(r.Scripts)
+}
+
+func filterURLs(list []string) (res []string) {
+	for _, src := range list {
+		if hasURLPrefix(src) {
+			res = append(res, src)
+		}
+	}
 	return
 }
 
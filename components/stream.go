@@ -0,0 +1,221 @@
+package components
+
+import (
+	"io"
+	"strings"
+
+	"github.com/flosch/pongo2"
+	"golang.org/x/net/context"
+
+	"tower.pro/renderer/template"
+)
+
+// childrenMarker - Marks place of component body in a layout rendered for streaming.
+const childrenMarker = "<!--renderer:children-->"
+
+// errorMarker - Written when rendering fails after document head was written.
+const errorMarker = "<!--renderer:error-->"
+
+// Stream - Component HTML streamed in parts.
+//
+// Layout of the component (extended components) is rendered first with a marker
+// in place of `children`. Document head with collected head entries and styles
+// is written and flushed before the component body is rendered.
+//
+// Styles, scripts and head entries are collected before rendering any body,
+// so they cannot depend on bodies of required components or `children`.
+//
+// Required components with `Defer` are rendered in background, placeholders
+// are rendered in their place and replaced with components streamed after the body
+// in order they are rendered.
+// Components without a layout or with a layout without `<head>` are rendered at once.
+type Stream struct {
+	// Preload - Inserts preload link tags for URL styles and scripts into head.
+	Preload bool
+
+	// Context - Request context. Deferred components are not rendered
+	// nor waited for when it's done. Background context is used when nil.
+	Context context.Context
+
+	c   *Compiled
+	ctx template.Context
+	res *Rendered
+
+	// prefix and suffix of a layout
+	// empty if component is rendered at once
+	prefix, suffix string
+}
+
+// flusher - Writer which can flush buffered data (eq. `http.Flusher`).
+type flusher interface {
+	Flush()
+}
+
+// NewStream - Prepares compiled component for streaming.
+// Renders layout of the component and collects assets.
+// Sets source component in template context under key `source_component`.
+func NewStream(c *Compiled, ctx template.Context) (s *Stream, err error) {
+	if ctx == nil {
+		ctx = make(template.Context)
+	}
+	ctx["source_component"] = c.Component
+	s = &Stream{c: c, ctx: ctx, res: new(Rendered)}
+
+	// Render at once if component has no layout
+	if c.Extends == nil {
+		return s, s.renderAll()
+	}
+
+	// Collect styles, scripts and head entries of the whole tree
+	err = collectAssets(c, s.res, ctx)
+	if err != nil {
+		return
+	}
+
+	// Render layout with a marker in place of children
+	// Assets are already collected so layout is rendered to a separate result
+	layout := new(Rendered)
+	ctx["children"] = pongo2.AsSafeValue(childrenMarker)
+	err = renderComponent(c.Extends, layout, layout, ctx, node{})
+	delete(ctx, "children")
+	if err != nil {
+		return
+	}
+	s.res.Errors = append(s.res.Errors, layout.Errors...)
+
+	// Render at once if layout cannot be split
+	index := strings.Index(layout.Body, childrenMarker)
+	if index == -1 || !strings.Contains(layout.Body[:index], "</head>") {
+		return s, s.renderAll()
+	}
+	s.prefix = layout.Body[:index]
+	s.suffix = layout.Body[index+len(childrenMarker):]
+	return
+}
+
+// Rendered - Returns rendered result. Before `WriteTo` is called,
+// it contains only collected styles, scripts and head entries.
+func (s *Stream) Rendered() *Rendered {
+	return s.res
+}
+
+// WriteTo - Writes document head, flushes it if writer is a flusher,
+// renders component body and writes it with the rest of the document.
+// When rendering fails after head was written, an error marker comment
+// is written in place of the rest of the document.
+func (s *Stream) WriteTo(w io.Writer) (n int64, err error) {
+	// Write whole document if rendered at once
+	if s.prefix == "" {
+		return write(w, n, s.html(s.res))
+	}
+
+	// Write document head with collected entries and styles
+	n, err = write(w, n, s.html(&Rendered{
+		Body:      s.prefix,
+		Styles:    s.res.Styles,
+		Head:      s.res.Head,
+		NoPreload: s.res.NoPreload,
+	}))
+	if err != nil {
+		return
+	}
+	flush(w)
+
+	// Render and write component body
+	styles := len(s.res.Styles)
+	body := new(Rendered)
+	ctx := s.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	s.res.deferred = &deferredQueue{ctx: ctx}
+	err = renderBody(s.c, s.res, body, s.ctx, node{})
+	if err != nil {
+		return abort(w, n, err)
+	}
+	n, err = write(w, n, body.Body)
+	if err != nil {
+		return
+	}
+	flush(w)
+
+	// Write deferred components as they are rendered
+	ready := s.res.deferred.ready()
+	for index := range s.res.deferred.list {
+		var chunk string
+		select {
+		case d := <-ready:
+			chunk = d.merge(s.res)
+		case <-ctx.Done():
+			return abort(w, n, ctx.Err())
+		}
+		if index == 0 {
+			chunk = deferredScript + chunk
+		}
+		n, err = write(w, n, chunk)
+		if err != nil {
+			return
+		}
+		flush(w)
+	}
+
+	// Write styles collected after head was written, scripts and rest of the document
+	extras := renderList(renderStyle, s.res.Styles[styles:])
+	extras = append(extras, renderList(renderScript, s.res.Scripts)...)
+	suffix, ok := insertBefore(s.suffix, "</html>", extras)
+	if !ok {
+		suffix = s.suffix + strings.Join(extras, "")
+	}
+	return write(w, n, suffix)
+}
+
+// renderAll - Renders component at once.
+func (s *Stream) renderAll() error {
+	return renderComponent(s.c, s.res, s.res, s.ctx, node{})
+}
+
+func (s *Stream) html(r *Rendered) string {
+	if s.Preload {
+		return r.PreloadHTML()
+	}
+	return r.HTML()
+}
+
+// collectAssets - Renders styles, scripts and head entries of a component tree
+// without rendering any bodies. Sets component defaults in context.
+func collectAssets(c *Compiled, main *Rendered, ctx template.Context) (err error) {
+	ctx, err = withComponentDefaults(c, ctx)
+	if err != nil {
+		return
+	}
+	for _, req := range c.Require {
+		err = collectAssets(req, main, ctx)
+		if err != nil {
+			return
+		}
+	}
+	if c.Extends != nil {
+		err = collectAssets(c.Extends, main, ctx)
+		if err != nil {
+			return
+		}
+	}
+	return renderAssets(c, main, ctx, node{})
+}
+
+// abort - Writes error marker and returns error.
+func abort(w io.Writer, n int64, err error) (int64, error) {
+	n, _ = write(w, n, errorMarker)
+	return n, err
+}
+
+func write(w io.Writer, n int64, body string) (int64, error) {
+	m, err := io.WriteString(w, body)
+	return n + int64(m), err
+}
+
+func flush(w io.Writer) {
+	if f, ok := w.(flusher); ok {
+		f.Flush()
+	}
+}
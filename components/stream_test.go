@@ -0,0 +1,210 @@
+package components
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"tower.pro/renderer/template"
+)
+
+type flushRecorder struct {
+	bytes.Buffer
+	flushed []string
+	onFlush func(string)
+}
+
+func (w *flushRecorder) Flush() {
+	w.flushed = append(w.flushed, w.String())
+	if w.onFlush != nil {
+		w.onFlush(w.String())
+	}
+}
+
+func TestStream(t *testing.T) {
+	layout := &Compiled{
+		Component: &Component{Name: "site.root"},
+		Main:      mustTemplate(t, "<html><head><title>{{ title }}</title></head><body>{{ children }}</body></html>"),
+		Styles:    []template.Template{template.Text("/site.css")},
+		Scripts:   []template.Template{template.Text("/site.js")},
+	}
+	page := &Compiled{
+		Component: &Component{Name: "site.list"},
+		Main:      mustTemplate(t, "<ul>{% for item in items %}<li>{{ item }}</li>{% endfor %}</ul>"),
+		Extends:   layout,
+		With:      template.Map{"title": mustMapNode(t, "List")},
+	}
+
+	s, err := NewStream(page, template.Context{"items": []string{"a", "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := new(flushRecorder)
+	if _, err = s.WriteTo(w); err != nil {
+		t.Fatal(err)
+	}
+
+	head := `<html><head><title>List</title><link rel="stylesheet" href="/site.css" /></head><body>`
+	if len(w.flushed) != 2 || w.flushed[0] != head {
+		t.Fatalf("Unexpected flushed parts: %q", w.flushed)
+	}
+	expected := head + `<ul><li>a</li><li>b</li></ul></body><script src="/site.js"></script></html>`
+	if w.String() != expected {
+		t.Errorf("Unexpected body: %s", w.String())
+	}
+	if strings.Contains(w.String(), childrenMarker) {
+		t.Error("Children marker was not replaced")
+	}
+}
+
+func TestStreamError(t *testing.T) {
+	layout := &Compiled{
+		Component: &Component{Name: "site.root"},
+		Main:      mustTemplate(t, "<html><head></head><body>{{ children }}</body></html>"),
+	}
+	page := &Compiled{
+		Component: &Component{Name: "site.page"},
+		Main:      mustTemplate(t, "{{ fail() }}"),
+		Extends:   layout,
+	}
+	ctx := template.Context{"fail": func() (string, error) {
+		return "", errors.New("failed")
+	}}
+	s, err := NewStream(page, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ctx["children"]; ok {
+		t.Error("Children marker was left in context")
+	}
+	w := new(flushRecorder)
+	if _, err = s.WriteTo(w); err == nil {
+		t.Fatal("Expected render error")
+	}
+	if expected := "<html><head></head><body>" + errorMarker; w.String() != expected {
+		t.Errorf("Unexpected body: %s", w.String())
+	}
+}
+
+func mustMapNode(t *testing.T, text string) template.MapNode {
+	m, err := template.ParseMap(template.Context{"node": text})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m["node"]
+}
+
+func TestStreamDeferred(t *testing.T) {
+	layout := &Compiled{
+		Component: &Component{Name: "site.root"},
+		Main:      mustTemplate(t, "<html><head></head><body>{{ children }}</body></html>"),
+	}
+	stats := &Compiled{
+		Component: &Component{Name: "site.stats", Defer: true},
+		Main:      mustTemplate(t, "<p>{{ visits }}</p>"),
+	}
+	page := &Compiled{
+		Component: &Component{Name: "site.page"},
+		Main:      mustTemplate(t, "<h1>Page</h1>{{ stats }}"),
+		Extends:   layout,
+		Require:   map[string]*Compiled{"stats": stats},
+	}
+
+	s, err := NewStream(page, template.Context{"visits": 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := new(flushRecorder)
+	if _, err = s.WriteTo(w); err != nil {
+		t.Fatal(err)
+	}
+
+	placeholder := `<h1>Page</h1><div id="renderer-deferred-1" class="renderer-deferred"></div>`
+	if len(w.flushed) != 3 || !strings.HasSuffix(w.flushed[1], placeholder) {
+		t.Fatalf("Unexpected flushed parts: %q", w.flushed)
+	}
+	chunk := `<template id="renderer-deferred-1-content"><p>10</p></template><script>rendererSwap("renderer-deferred-1")</script>`
+	if !strings.HasSuffix(w.flushed[2], chunk) {
+		t.Errorf("Unexpected deferred chunk: %q", w.flushed[2])
+	}
+
+	// Deferred components are rendered in place when not streaming
+	res, err := Render(page, template.Context{"visits": 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(res.Body, "<h1>Page</h1><p>10</p>") {
+		t.Errorf("Unexpected body: %s", res.Body)
+	}
+}
+
+func TestStreamDeferredOrder(t *testing.T) {
+	layout := &Compiled{
+		Component: &Component{Name: "site.root"},
+		Main:      mustTemplate(t, "<html><head></head><body>{{ children }}</body></html>"),
+	}
+	slow := &Compiled{
+		Component: &Component{Name: "site.slow", Defer: true},
+		Main:      mustTemplate(t, "<p>{{ wait() }}slow</p>"),
+	}
+	fast := &Compiled{
+		Component: &Component{Name: "site.fast", Defer: true},
+		Main:      mustTemplate(t, "<p>fast</p>"),
+	}
+	page := &Compiled{
+		Component: &Component{Name: "site.page"},
+		Main:      mustTemplate(t, "{{ slow }}{{ fast }}"),
+		Extends:   layout,
+		Require:   map[string]*Compiled{"slow": slow, "fast": fast},
+	}
+
+	// Slow component is rendered after fast one is written
+	release := make(chan struct{})
+	wait := func() string {
+		<-release
+		return ""
+	}
+	s, err := NewStream(page, template.Context{"wait": wait})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	s.Context = ctx
+	w := &flushRecorder{onFlush: func(body string) {
+		if strings.Contains(body, "<p>fast</p>") && !strings.Contains(body, "<p>slow</p>") {
+			close(release)
+		}
+	}}
+	if _, err = s.WriteTo(w); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(w.String(), "<p>slow</p>") {
+		t.Errorf("Unexpected body: %s", w.String())
+	}
+
+	// Deferred components are not waited for when request is canceled
+	block := make(chan struct{})
+	defer close(block)
+	s, err = NewStream(page, template.Context{"wait": func() string {
+		<-block
+		return ""
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	s.Context = ctx
+	w = &flushRecorder{onFlush: func(body string) {
+		if strings.Contains(body, "<p>fast</p>") {
+			cancel()
+		}
+	}}
+	if _, err = s.WriteTo(w); err != context.Canceled {
+		t.Errorf("Expected canceled error, got %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package components
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Trace - Component render trace.
+// Durations of a component include durations of its requires and extends.
+type Trace struct {
+	// Name - Component name.
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+
+	// Key - Key of the component in parent (require key, `extends` or `fallback`).
+	Key string `json:"key,omitempty" yaml:"key,omitempty"`
+
+	// Compile - Component compile duration.
+	Compile time.Duration `json:"compile,omitempty" yaml:"compile,omitempty"`
+
+	// Render - Component render duration.
+	Render time.Duration `json:"render,omitempty" yaml:"render,omitempty"`
+
+	// Size - Size of component body in bytes (without extended component).
+	Size int `json:"size,omitempty" yaml:"size,omitempty"`
+
+	// Children - Traces of required and extended components.
+	Children []*Trace `json:"children,omitempty" yaml:"children,omitempty"`
+}
+
+// newTrace - Creates a new trace of compiled component.
+func newTrace(key string, c *Compiled) *Trace {
+	return &Trace{
+		Name:    c.Name,
+		Key:     key,
+		Compile: c.CompileTime,
+	}
+}
+
+// child - Creates a trace of a child component.
+// Returns nil if tracing is disabled (`tr` is nil).
+func (tr *Trace) child(key string, c *Compiled) (child *Trace) {
+	if tr == nil {
+		return
+	}
+	child = newTrace(key, c)
+	tr.Children = append(tr.Children, child)
+	return
+}
+
+// String - Returns trace tree in human readable format.
+func (tr *Trace) String() string {
+	var buf bytes.Buffer
+	tr.write(&buf, 0)
+	return buf.String()
+}
+
+func (tr *Trace) write(buf *bytes.Buffer, depth int) {
+	buf.WriteString(strings.Repeat("  ", depth))
+	if tr.Key != "" {
+		fmt.Fprintf(buf, "%s: ", tr.Key)
+	}
+	fmt.Fprintf(buf, "%s compile=%v render=%v size=%d\n", tr.Name, tr.Compile, tr.Render, tr.Size)
+	for _, child := range tr.Children {
+		child.write(buf, depth+1)
+	}
+}
@@ -0,0 +1,90 @@
+package components
+
+import (
+	"sort"
+
+	"tower.pro/renderer/template"
+)
+
+// Tree - Rendered components tree.
+type Tree struct {
+	// Name - Component name.
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+
+	// Body - Component body (without extended component).
+	Body string `json:"body,omitempty" yaml:"body,omitempty"`
+
+	// Styles - Component own styles.
+	Styles []string `json:"styles,omitempty" yaml:"styles,omitempty"`
+
+	// Scripts - Component own scripts.
+	Scripts []string `json:"scripts,omitempty" yaml:"scripts,omitempty"`
+
+	// Context - Template context keys component was rendered with.
+	Context []string `json:"context,omitempty" yaml:"context,omitempty"`
+
+	// Require - Required components trees.
+	Require map[string]*Tree `json:"require,omitempty" yaml:"require,omitempty"`
+
+	// Extends - Extended component tree.
+	Extends *Tree `json:"extends,omitempty" yaml:"extends,omitempty"`
+}
+
+// node - Records of a rendered component.
+// Trace and tree are nil when not recorded.
+type node struct {
+	trace *Trace
+	tree  *Tree
+}
+
+// child - Creates records of a required (by key) or extended (key `extends`) component.
+func (n node) child(key string, c *Compiled) (child node) {
+	child.trace = n.trace.child(key, c)
+	if n.tree == nil {
+		return
+	}
+	child.tree = &Tree{Name: c.Name}
+	if key == "extends" {
+		n.tree.Extends = child.tree
+		return
+	}
+	if n.tree.Require == nil {
+		n.tree.Require = make(map[string]*Tree)
+	}
+	n.tree.Require[key] = child.tree
+	return
+}
+
+// fallback - Creates records of a fallback rendered in place of component.
+// Fallback tree replaces the component tree.
+func (n node) fallback(c *Compiled) (child node) {
+	child.trace = n.trace.child("fallback", c)
+	if n.tree != nil {
+		*n.tree = Tree{Name: c.Name}
+		child.tree = n.tree
+	}
+	return
+}
+
+// setBody - Records component body and context keys.
+func (n node) setBody(body string, ctx template.Context) {
+	if n.trace != nil {
+		n.trace.Size = len(body)
+	}
+	if n.tree == nil {
+		return
+	}
+	n.tree.Body = body
+	n.tree.Context = make([]string, 0, len(ctx))
+	for key := range ctx {
+		n.tree.Context = append(n.tree.Context, key)
+	}
+	sort.Strings(n.tree.Context)
+}
+
+// setAssets - Records component styles and scripts.
+func (n node) setAssets(styles, scripts []string) {
+	if n.tree != nil {
+		n.tree.Styles, n.tree.Scripts = styles, scripts
+	}
+}
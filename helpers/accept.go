@@ -0,0 +1,78 @@
+package helpers
+
+import (
+	"strconv"
+	"strings"
+)
+
+// MediaRange - Media range from `Accept` header.
+type MediaRange struct {
+	// Type - Media type or range, eq. `text/html`, `text/*` or `*/*`.
+	Type string
+
+	// Params - Media type parameters without quality, eq. `profile`.
+	Params map[string]string
+
+	// Q - Quality of the media range, from 0 to 1.
+	Q float64
+}
+
+// ParseAccept - Parses `Accept` header into a list of media ranges.
+// Ranges with invalid quality are skipped. Empty header accepts any type.
+func ParseAccept(header string) (ranges []MediaRange) {
+	if strings.TrimSpace(header) == "" {
+		return []MediaRange{{Type: "*/*", Q: 1}}
+	}
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		m := MediaRange{Type: strings.ToLower(strings.TrimSpace(params[0])), Q: 1}
+		if m.Type == "" {
+			continue
+		}
+		if m.Type == "*" {
+			m.Type = "*/*"
+		}
+		valid := true
+		for _, param := range params[1:] {
+			kv := strings.SplitN(param, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			key := strings.ToLower(strings.TrimSpace(kv[0]))
+			value := strings.Trim(strings.TrimSpace(kv[1]), `"`)
+			if key != "q" {
+				if m.Params == nil {
+					m.Params = make(map[string]string)
+				}
+				m.Params[key] = value
+				continue
+			}
+			q, err := strconv.ParseFloat(value, 64)
+			if err != nil || q < 0 || q > 1 {
+				valid = false
+				break
+			}
+			m.Q = q
+		}
+		if valid {
+			ranges = append(ranges, m)
+		}
+	}
+	return
+}
+
+// Match - Returns specificity of a match of media type with the range
+// or -1 if it doesn't match. Exact match is the most specific.
+func (m MediaRange) Match(typ string) int {
+	switch {
+	case m.Type == typ:
+		return 2
+	case strings.HasSuffix(m.Type, "/*") && m.Type != "*/*":
+		if strings.HasPrefix(typ, m.Type[:len(m.Type)-1]) {
+			return 1
+		}
+	case m.Type == "*/*":
+		return 0
+	}
+	return -1
+}
@@ -0,0 +1,28 @@
+package helpers
+
+import "net/http"
+
+// WithFlusher - Returns response writer `w` implementing `http.Flusher`
+// only if `parent` response writer it writes to implements it.
+// Flushing calls `flush` with `parent` flusher if set, otherwise flushes `parent`.
+func WithFlusher(w, parent http.ResponseWriter, flush func(http.Flusher)) http.ResponseWriter {
+	f, ok := parent.(http.Flusher)
+	if !ok {
+		return w
+	}
+	if flush == nil {
+		return &flushWriter{ResponseWriter: w, flush: f.Flush}
+	}
+	return &flushWriter{ResponseWriter: w, flush: func() { flush(f) }}
+}
+
+// flushWriter - Response writer flushed with a function.
+type flushWriter struct {
+	http.ResponseWriter
+	flush func()
+}
+
+// Flush - Flushes response writer.
+func (fw *flushWriter) Flush() {
+	fw.flush()
+}
@@ -0,0 +1,105 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValueError - Error of conversion of a request value to a type.
+type ValueError struct {
+	Key string
+	Err error
+}
+
+// Error - Returns error message.
+func (e *ValueError) Error() string {
+	return fmt.Sprintf("invalid value of %q: %v", e.Key, e.Err)
+}
+
+// valueTypes - Types of values which can be set in keys.
+var valueTypes = map[string]bool{
+	"string": true, "int": true, "int64": true, "float": true,
+	"bool": true, "duration": true, "json": true, "list": true,
+}
+
+// ParseKey - Parses key of a value in format `name[:type][=default]`,
+// eq. `page:int=1`. Returns nil default if not set. Suffix which is not
+// a known type is a part of the name, eq. `urn:isbn`.
+func ParseKey(key string) (name, typ string, def *string) {
+	name = key
+	if index := strings.Index(name, "="); index >= 0 {
+		value := name[index+1:]
+		def = &value
+		name = name[:index]
+	}
+	if index := strings.LastIndex(name, ":"); index >= 0 && valueTypes[name[index+1:]] {
+		typ = name[index+1:]
+		name = name[:index]
+	}
+	return
+}
+
+// ZeroValue - Returns zero value of a type, eq. `0` for `int`.
+// Returns nil for `json` and `list` types.
+func ZeroValue(typ string) interface{} {
+	switch typ {
+	case "", "string":
+		return ""
+	case "int":
+		return 0
+	case "int64":
+		return int64(0)
+	case "float":
+		return float64(0)
+	case "bool":
+		return false
+	case "duration":
+		return time.Duration(0)
+	}
+	return nil
+}
+
+// ParseValue - Converts values to a type. Types are `string`, `int`, `int64`,
+// `float`, `bool`, `duration`, `json` and `list`. All types except `list`
+// are converted from first value, `list` contains all comma-separated values.
+func ParseValue(typ string, values []string) (_ interface{}, err error) {
+	if typ == "list" {
+		var res []interface{}
+		for _, value := range values {
+			for _, v := range strings.Split(value, ",") {
+				if v = strings.TrimSpace(v); v != "" {
+					res = append(res, v)
+				}
+			}
+		}
+		return res, nil
+	}
+	var value string
+	if len(values) != 0 {
+		value = values[0]
+	}
+	switch typ {
+	case "", "string":
+		return value, nil
+	case "int":
+		return strconv.Atoi(value)
+	case "int64":
+		return strconv.ParseInt(value, 10, 64)
+	case "float":
+		return strconv.ParseFloat(value, 64)
+	case "bool":
+		return strconv.ParseBool(value)
+	case "duration":
+		return time.ParseDuration(value)
+	case "json":
+		var v interface{}
+		if err = json.Unmarshal([]byte(value), &v); err != nil {
+			return
+		}
+		return CleanDeep(v)
+	}
+	return nil, fmt.Errorf("unknown type %q", typ)
+}
@@ -0,0 +1,52 @@
+package helpers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseValue(t *testing.T) {
+	tests := []struct {
+		key    string
+		values []string
+		want   interface{}
+	}{
+		{"page", []string{"2"}, "2"},
+		{"page:int", []string{"2"}, 2},
+		{"id:int64", []string{"9007199254740993"}, int64(9007199254740993)},
+		{"price:float=1.5", nil, 1.5},
+		{"draft:bool", []string{"true"}, true},
+		{"ttl:duration=1m", nil, time.Minute},
+		{"tags:list", []string{"a,b", "c"}, []interface{}{"a", "b", "c"}},
+		{"filter:json", []string{`{"a": [1]}`}, map[string]interface{}{"a": []interface{}{float64(1)}}},
+	}
+	for _, test := range tests {
+		name, typ, def := ParseKey(test.key)
+		values := test.values
+		if len(values) == 0 && def != nil {
+			values = []string{*def}
+		}
+		v, err := ParseValue(typ, values)
+		if err != nil {
+			t.Errorf("%s (%s): %v", test.key, name, err)
+			continue
+		}
+		if m, ok := test.want.(map[string]interface{}); ok {
+			test.want, _ = CleanDeepMap(m)
+		}
+		if !reflect.DeepEqual(v, test.want) {
+			t.Errorf("%s: got %#v, expected %#v", test.key, v, test.want)
+		}
+	}
+
+	if _, err := ParseValue("int", []string{"abc"}); err == nil {
+		t.Error("Expected int conversion error")
+	}
+	if _, err := ParseValue("date", []string{"abc"}); err == nil {
+		t.Error("Expected unknown type error")
+	}
+	if name, typ, _ := ParseKey("urn:isbn"); name != "urn:isbn" || typ != "" {
+		t.Errorf("Unknown type should be part of name, got %q %q", name, typ)
+	}
+}
@@ -1,6 +1,15 @@
 package middlewares
 
-import "fmt"
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/rs/xhandler"
+	"golang.org/x/net/context"
+
+	"tower.pro/renderer/helpers"
+	"tower.pro/renderer/options"
+)
 
 // Registry - Middlewares registry.
 type Registry struct {
@@ -60,11 +69,46 @@ func (registry *Registry) Construct(m *Middleware) (Handler, error) {
 	if err := m.Validate(md.Descriptor.Options); err != nil {
 		return nil, fmt.Errorf("In middleware %q: %v", md.Descriptor.Name, err)
 	}
-	opts, err := m.ConstructOptions()
+	opts, err := constructOptions(m)
+	if err != nil {
+		return nil, err
+	}
+	handler, err := md.Constructor(opts)
 	if err != nil {
 		return nil, err
 	}
-	return md.Constructor(opts)
+	return checkValues(opts, md.Descriptor.Options, handler), nil
+}
+
+// checkValues - Resolves options values from context keys once per request.
+// Responds with `400 Bad Request` if request value of a required option
+// cannot be converted to its type, eq. when `request.url.query.page:int`
+// is not a number.
+func checkValues(opts *middlewareOpts, descs []*options.Option, handler Handler) Handler {
+	var keyed []*options.Option
+	for _, desc := range descs {
+		if _, ok := opts.context[desc.Name]; ok {
+			keyed = append(keyed, desc)
+		}
+	}
+	if len(keyed) == 0 {
+		return handler
+	}
+	return func(next xhandler.HandlerC) xhandler.HandlerC {
+		h := handler(next)
+		return xhandler.HandlerFuncC(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			values := make(map[string]interface{}, len(keyed))
+			for _, desc := range keyed {
+				v, err := opts.contextValue(ctx, desc)
+				if err != nil {
+					helpers.WriteError(w, r, http.StatusBadRequest, err.Error())
+					return
+				}
+				values[desc.Name] = v
+			}
+			h.ServeHTTPC(context.WithValue(ctx, opts, values), w, r)
+		})
+	}
 }
 
 // Exists - Checks if middleware with given name exists in registry.
@@ -162,15 +162,16 @@ func (opts *middlewareOpts) Map(ctx context.Context, desc *options.Option) (res
 		}
 		res = helpers.WithDefaultsMap(res, v)
 	}
-	if node, ok := opts.context[desc.Name]; ok {
-		opt := node.Value(ctx)
-		if value, ok := opt.(template.Context); ok {
-			res = helpers.WithDefaultsMap(res, value)
-		} else if opt != nil {
-			return nil, fmt.Errorf("invalid context value for option %q: %#v", desc.Name, opt)
-		}
+	opt, err := opts.contextValue(ctx, desc)
+	if err != nil {
+		return nil, err
+	}
+	if value, ok := opt.(template.Context); ok {
+		res = helpers.WithDefaultsMap(res, value)
+	} else if opt != nil {
+		return nil, fmt.Errorf("invalid context value for option %q: %#v", desc.Name, opt)
 	}
-	opt := opts.options[desc.Name]
+	opt = opts.options[desc.Name]
 	if options, ok := opt.(template.Context); ok {
 		res = helpers.WithDefaultsMap(res, options)
 	} else if opt != nil {
@@ -192,10 +193,12 @@ func (opts *middlewareOpts) List(ctx context.Context, desc *options.Option) (res
 			res = append(res, values...)
 		}
 	}
-	if node, ok := opts.context[desc.Name]; ok {
-		if value, ok := node.Value(ctx).([]interface{}); ok {
-			res = append(res, value...)
-		}
+	value, err := opts.contextValue(ctx, desc)
+	if err != nil {
+		return nil, err
+	}
+	if value, ok := value.([]interface{}); ok {
+		res = append(res, value...)
 	}
 	if options, ok := opts.options[desc.Name].([]interface{}); ok {
 		res = append(res, options...)
@@ -221,11 +224,12 @@ func (opts *middlewareOpts) StringList(ctx context.Context, desc *options.Option
 }
 
 func (opts *middlewareOpts) getValue(ctx context.Context, desc *options.Option) (_ interface{}, _ error) {
-	node, ok := opts.context[desc.Name]
-	if ok {
-		if v := node.Value(ctx); v != nil {
-			return v, nil
-		}
+	v, err := opts.contextValue(ctx, desc)
+	if err != nil {
+		return nil, err
+	}
+	if v != nil {
+		return v, nil
 	}
 	template, ok := opts.template[desc.Name]
 	if ok {
@@ -246,6 +250,54 @@ func (opts *middlewareOpts) getValue(ctx context.Context, desc *options.Option)
 	return
 }
 
+// contextValue - Gets option value from context keys. Invalid request values
+// (`*helpers.ValueError`) are errors of required options and empty otherwise.
+// Values resolved by `checkValues` are read from context.
+func (opts *middlewareOpts) contextValue(ctx context.Context, desc *options.Option) (interface{}, error) {
+	node, ok := opts.context[desc.Name]
+	if !ok {
+		return nil, nil
+	}
+	if values, ok := ctx.Value(opts).(map[string]interface{}); ok {
+		if v, ok := values[desc.Name]; ok {
+			return v, nil
+		}
+	}
+	v, err := cleanValueErrors(node.Value(ctx))
+	if err != nil && desc.Always {
+		return nil, err
+	}
+	return v, nil
+}
+
+// cleanValueErrors - Returns copy of a value with `*helpers.ValueError`
+// values in maps and lists replaced with nil and first of those errors.
+func cleanValueErrors(value interface{}) (_ interface{}, err error) {
+	switch t := value.(type) {
+	case *helpers.ValueError:
+		return nil, t
+	case template.Context:
+		res := make(template.Context, len(t))
+		for key, v := range t {
+			var e error
+			if res[key], e = cleanValueErrors(v); e != nil && err == nil {
+				err = e
+			}
+		}
+		return res, err
+	case []interface{}:
+		res := make([]interface{}, len(t))
+		for index, v := range t {
+			var e error
+			if res[index], e = cleanValueErrors(v); e != nil && err == nil {
+				err = e
+			}
+		}
+		return res, err
+	}
+	return value, nil
+}
+
 func valueInt(value interface{}) (_ int, _ bool, _ error) {
 	switch t := value.(type) {
 	case int:
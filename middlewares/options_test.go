@@ -1,10 +1,16 @@
 package middlewares
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/rs/xhandler"
 	"golang.org/x/net/context"
 
+	"tower.pro/renderer/helpers"
 	"tower.pro/renderer/options"
 	"tower.pro/renderer/template"
 )
@@ -37,3 +43,57 @@ func TestConstructOptions(t *testing.T) {
 	}
 
 }
+
+func TestConstructInvalidValue(t *testing.T) {
+	opt := &options.Option{Name: "page", Type: options.TypeInt, Always: true}
+	registry := New()
+	registry.Register(Descriptor{Name: "test.page", Options: []*options.Option{opt}}, func(opts Options) (Handler, error) {
+		return ToHandler(func(ctx context.Context, w http.ResponseWriter, r *http.Request, next xhandler.HandlerC) {
+			page, err := opts.Int(ctx, opt)
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			fmt.Fprintf(w, "page %d", page)
+		}), nil
+	})
+	h, err := registry.Construct(&Middleware{Name: "test.page", Context: template.Context{"page": "page:int"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(value interface{}) *httptest.ResponseRecorder {
+		ctx := context.WithValue(context.Background(), "page:int", value)
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/", nil)
+		h(nil).ServeHTTPC(ctx, w, r)
+		return w
+	}
+	if w := serve(2); w.Code != http.StatusOK || w.Body.String() != "page 2" {
+		t.Errorf("Invalid response %d: %s", w.Code, w.Body.String())
+	}
+	if w := serve(&helpers.ValueError{Key: "page:int", Err: errors.New("invalid syntax")}); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected bad request %d: %s", w.Code, w.Body.String())
+	}
+
+	// Invalid values nested in map options
+	query := &options.Option{Name: "query", Type: options.TypeMap, Always: true}
+	registry.Register(Descriptor{Name: "test.query", Options: []*options.Option{query}}, func(opts Options) (Handler, error) {
+		return ToHandler(func(ctx context.Context, w http.ResponseWriter, r *http.Request, next xhandler.HandlerC) {
+			q, err := opts.Map(ctx, query)
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			fmt.Fprintf(w, "page %v", q["page"])
+		}), nil
+	})
+	h, err = registry.Construct(&Middleware{Name: "test.query", Context: template.Context{"query": template.Context{"page": "page:int"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := serve(3); w.Code != http.StatusOK || w.Body.String() != "page 3" {
+		t.Errorf("Invalid response %d: %s", w.Code, w.Body.String())
+	}
+	if w := serve(&helpers.ValueError{Key: "page:int", Err: errors.New("invalid syntax")}); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected bad request %d: %s", w.Code, w.Body.String())
+	}
+}
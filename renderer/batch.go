@@ -0,0 +1,115 @@
+package renderer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/rs/xhandler"
+	"golang.org/x/net/context"
+
+	"tower.pro/renderer/compiler"
+	"tower.pro/renderer/components"
+	"tower.pro/renderer/helpers"
+	"tower.pro/renderer/template"
+)
+
+// BatchResult - Result of a component rendered in a batch.
+// Contains rendered component or an error if it failed to compile or render.
+type BatchResult struct {
+	*components.Rendered
+
+	// Error - Compile or render error of the component.
+	Error string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// NewBatch - New batch render web server API handler.
+// Accepts a list of components in JSON body of a request and responds
+// with a list of results in the same order. Components are rendered concurrently,
+// each with a copy of the request template context.
+// Context should have a compiler set with `compiler.NewContext`.
+func NewBatch(opts ...Option) xhandler.HandlerC {
+	return constructBatch(constructOpts(opts...))
+}
+
+func constructBatch(o *webOptions) xhandler.HandlerC {
+	var chain xhandler.Chain
+	chain.UseC(xhandler.CloseHandler)
+	chain.UseC(xhandler.TimeoutHandler(o.reqTimeout))
+	chain.UseC(optionsMiddleware(o))
+	chain.UseC(o.templateCtxSetter)
+	if o.compression {
+		chain.UseC(compressMiddleware(o.compressMin))
+	}
+	for _, m := range o.middlewares {
+		chain.UseC(m)
+	}
+	return chain.HandlerCF(RenderBatch)
+}
+
+// BatchLimit - Maximum number of components rendered in a batch.
+var BatchLimit = 100
+
+// RenderBatch - Renders components from JSON list in request body
+// and writes JSON list of results. Failure of a component doesn't
+// fail the whole batch, error is written in its result instead.
+// Responds with `413 Request Entity Too Large` if body exceeds
+// `DefaultBodyLimit` or list has more than `BatchLimit` components.
+func RenderBatch(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	if r.ContentLength > DefaultBodyLimit {
+		helpers.WriteError(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d bytes", DefaultBodyLimit))
+		return
+	}
+	lb := &limitedBody{ReadCloser: r.Body, remaining: DefaultBodyLimit}
+	var list []components.Component
+	if err := json.NewDecoder(http.MaxBytesReader(w, lb, DefaultBodyLimit)).Decode(&list); err != nil {
+		if lb.exceeded {
+			helpers.WriteError(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d bytes", DefaultBodyLimit))
+		} else {
+			helpers.WriteError(w, r, http.StatusBadRequest, err.Error())
+		}
+		return
+	}
+	if len(list) > BatchLimit {
+		helpers.WriteError(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("batch exceeds %d components", BatchLimit))
+		return
+	}
+
+	t, _ := components.TemplateContext(ctx)
+	results := make([]*BatchResult, len(list))
+
+	// Limit number of components rendered at once
+	sem := make(chan struct{}, runtime.NumCPU())
+	var wg sync.WaitGroup
+	for index := range list {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(index int) {
+			defer func() { <-sem; wg.Done() }()
+			ctx := components.NewContext(ctx, &list[index])
+			results[index] = renderBatchItem(ctx, t.Clone())
+		}(index)
+	}
+	wg.Wait()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(results); err != nil {
+		glog.Warningf("[api] response encode error: %v", err)
+	}
+}
+
+// renderBatchItem - Compiles and renders component from context.
+func renderBatchItem(ctx context.Context, t template.Context) *BatchResult {
+	c, err := compiler.Compile(ctx)
+	if err != nil {
+		return &BatchResult{Error: fmt.Sprintf("compile error: %v", err)}
+	}
+	res, err := components.Render(c, t)
+	if err != nil {
+		return &BatchResult{Error: fmt.Sprintf("render error: %v", err)}
+	}
+	return &BatchResult{Rendered: res}
+}
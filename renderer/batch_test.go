@@ -0,0 +1,92 @@
+package renderer
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"tower.pro/renderer/compiler"
+	"tower.pro/renderer/storage"
+)
+
+func TestRenderBatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "renderer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "test", "card"), 0755)
+	err = ioutil.WriteFile(filepath.Join(dir, "test", "card", "component.yaml"), []byte("main: template://<p>{{ title }}</p>"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := storage.New(storage.WithDir(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := compiler.NewContext(context.Background(), compiler.New(s))
+
+	body := `[
+		{"name": "test.card", "context": {"title": "first"}},
+		{"name": "test.missing"},
+		{"name": "test.card", "context": {"title": "second"}}
+	]`
+	r, _ := http.NewRequest("POST", "/batch", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	NewBatch().ServeHTTPC(ctx, w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Invalid status %d: %s", w.Code, w.Body.String())
+	}
+
+	var results []*BatchResult
+	if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Invalid number of results: %d", len(results))
+	}
+	if results[0].Rendered == nil || results[0].Body != "<p>first</p>" {
+		t.Errorf("Invalid first result: %#v", results[0])
+	}
+	if results[1].Error == "" {
+		t.Errorf("Expected error in second result: %#v", results[1])
+	}
+	if results[2].Rendered == nil || results[2].Body != "<p>second</p>" {
+		t.Errorf("Invalid third result: %#v", results[2])
+	}
+}
+
+func TestRenderBatchLimits(t *testing.T) {
+	serve := func(body io.Reader, length int64) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("POST", "/batch", body)
+		r.ContentLength = length
+		w := httptest.NewRecorder()
+		NewBatch().ServeHTTPC(context.Background(), w, r)
+		return w
+	}
+
+	list := "[" + strings.Repeat(`{"name": "test.card"},`, BatchLimit) + `{"name": "test.card"}]`
+	if w := serve(strings.NewReader(list), int64(len(list))); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected too many components error, got %d: %s", w.Code, w.Body.String())
+	}
+
+	large := `[{"name": "` + strings.Repeat("a", int(DefaultBodyLimit)) + `"}]`
+	if w := serve(strings.NewReader(large), int64(len(large))); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected body too large error, got %d: %s", w.Code, w.Body.String())
+	}
+	// Chunked body without content length
+	if w := serve(ioutil.NopCloser(strings.NewReader(large)), -1); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected chunked body too large error, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := serve(strings.NewReader("{"), 1); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected bad request, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,167 @@
+package renderer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+
+	"github.com/rs/xhandler"
+	"golang.org/x/net/context"
+
+	"tower.pro/renderer/components"
+	"tower.pro/renderer/helpers"
+	"tower.pro/renderer/middlewares"
+	"tower.pro/renderer/template"
+)
+
+// Body - Decoding of request body into template context.
+type Body struct {
+	// Key - Template context key of decoded body, `body` by default.
+	Key string `json:"key,omitempty" yaml:"key,omitempty"`
+
+	// Limit - Maximum size of request body in bytes, 1MB by default.
+	Limit int64 `json:"limit,omitempty" yaml:"limit,omitempty"`
+
+	// MaxMemory - Maximum size in bytes of multipart body kept in memory,
+	// rest of uploaded files is stored in temporary files. 1MB by default.
+	MaxMemory int64 `json:"max_memory,omitempty" yaml:"max_memory,omitempty"`
+}
+
+// DefaultBodyLimit - Default maximum size of decoded request body.
+var DefaultBodyLimit int64 = 1 << 20
+
+// DecodeBody - Decodes JSON, form and multipart request bodies
+// into template context key. Form values are strings or lists of strings
+// if repeated. Uploaded files are represented by `filename`, `size`,
+// `content_type` and `header` of a file (`*multipart.FileHeader`).
+// Responds with `413 Request Entity Too Large` if body exceeds limit
+// and with `415 Unsupported Media Type` on other content types.
+func DecodeBody(body *Body) middlewares.Handler {
+	key := body.Key
+	if key == "" {
+		key = "body"
+	}
+	limit := body.Limit
+	if limit <= 0 {
+		limit = DefaultBodyLimit
+	}
+	maxMemory := body.MaxMemory
+	if maxMemory <= 0 {
+		maxMemory = 1 << 20
+	}
+	return middlewares.ToHandler(func(ctx context.Context, w http.ResponseWriter, r *http.Request, next xhandler.HandlerC) {
+		if r.Body == nil || r.ContentLength == 0 || r.Method == "GET" || r.Method == "HEAD" {
+			next.ServeHTTPC(ctx, w, r)
+			return
+		}
+		if r.ContentLength > limit {
+			helpers.WriteError(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d bytes", limit))
+			return
+		}
+		lb := &limitedBody{ReadCloser: r.Body, remaining: limit}
+		r.Body = lb
+
+		typ, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			helpers.WriteError(w, r, http.StatusUnsupportedMediaType, err.Error())
+			return
+		}
+		var value interface{}
+		switch typ {
+		case "application/json":
+			if err = json.NewDecoder(r.Body).Decode(&value); err == nil {
+				value, err = helpers.CleanDeep(value)
+			}
+		case "application/x-www-form-urlencoded":
+			if err = r.ParseForm(); err == nil {
+				value = formValues(r.PostForm, nil)
+			}
+		case "multipart/form-data":
+			if err = r.ParseMultipartForm(maxMemory); err == nil {
+				defer r.MultipartForm.RemoveAll()
+				value = formValues(r.MultipartForm.Value, r.MultipartForm.File)
+			}
+		default:
+			helpers.WriteError(w, r, http.StatusUnsupportedMediaType, fmt.Sprintf("content type %q is not supported", typ))
+			return
+		}
+		if err != nil {
+			if lb.exceeded {
+				// Rest of the body is not read
+				w.Header().Set("Connection", "close")
+				helpers.WriteError(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d bytes", limit))
+			} else {
+				helpers.WriteError(w, r, http.StatusBadRequest, fmt.Sprintf("request body error: %v", err))
+			}
+			return
+		}
+
+		ctx = components.WithTemplateKey(ctx, key, value)
+		next.ServeHTTPC(ctx, w, r)
+	})
+}
+
+// errBodyTooLarge - Error of reading request body exceeding limit.
+var errBodyTooLarge = errors.New("request body too large")
+
+// limitedBody - Request body failing to read more than `remaining` bytes.
+// It records when limit is exceeded, so it's detected even
+// if the error is wrapped by a decoder.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	exceeded  bool
+}
+
+func (b *limitedBody) Read(p []byte) (n int, err error) {
+	if b.exceeded {
+		return 0, errBodyTooLarge
+	}
+	// Read one byte more than remaining to detect exceeding body
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err = b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		n = int(b.remaining)
+		b.remaining = 0
+		b.exceeded = true
+		return n, errBodyTooLarge
+	}
+	b.remaining -= int64(n)
+	return
+}
+
+// formValues - Converts form values and files to template context.
+func formValues(values url.Values, files map[string][]*multipart.FileHeader) template.Context {
+	res := make(template.Context, len(values)+len(files))
+	for name, list := range values {
+		if len(list) == 1 {
+			res[name] = list[0]
+		} else {
+			res[name] = list
+		}
+	}
+	for name, list := range files {
+		var uploads []interface{}
+		for _, header := range list {
+			uploads = append(uploads, template.Context{
+				"filename":     header.Filename,
+				"size":         header.Size,
+				"content_type": header.Header.Get("Content-Type"),
+				"header":       header,
+			})
+		}
+		if len(uploads) == 1 {
+			res[name] = uploads[0]
+		} else {
+			res[name] = uploads
+		}
+	}
+	return res
+}
@@ -0,0 +1,81 @@
+package renderer
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/xhandler"
+	"golang.org/x/net/context"
+
+	"tower.pro/renderer/components"
+	"tower.pro/renderer/template"
+)
+
+func TestDecodeBody(t *testing.T) {
+	var decoded interface{}
+	h := DecodeBody(&Body{Key: "form", Limit: 1024})(xhandler.HandlerFuncC(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		decoded, _ = components.TemplateValue(ctx, "form")
+	}))
+	serve := func(typ, body string) *httptest.ResponseRecorder {
+		decoded = nil
+		r, _ := http.NewRequest("POST", "/", strings.NewReader(body))
+		r.Header.Set("Content-Type", typ)
+		w := httptest.NewRecorder()
+		h.ServeHTTPC(components.NewTemplateContext(context.Background(), template.Context{}), w, r)
+		return w
+	}
+
+	serve("application/json", `{"user": {"name": "John"}}`)
+	if v, _ := decoded.(template.Context).Get("user.name").(string); v != "John" {
+		t.Errorf("Invalid JSON body %#v", decoded)
+	}
+
+	serve("application/x-www-form-urlencoded", "name=John&tag=a&tag=b")
+	form, _ := decoded.(template.Context)
+	if form["name"] != "John" || len(form["tag"].([]string)) != 2 {
+		t.Errorf("Invalid form body %#v", decoded)
+	}
+
+	buf := new(bytes.Buffer)
+	mw := multipart.NewWriter(buf)
+	mw.WriteField("name", "John")
+	fw, _ := mw.CreateFormFile("avatar", "avatar.png")
+	fw.Write([]byte("png"))
+	mw.Close()
+	serve(mw.FormDataContentType(), buf.String())
+	form, _ = decoded.(template.Context)
+	avatar, _ := form["avatar"].(template.Context)
+	if form["name"] != "John" || avatar["filename"] != "avatar.png" || avatar["size"] != int64(3) {
+		t.Errorf("Invalid multipart body %#v", decoded)
+	}
+
+	if w := serve("application/json", `{"text": "`+strings.Repeat("a", 1024)+`"}`); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected too large error %d: %s", w.Code, w.Body.String())
+	}
+	if w := serve("application/json", `{"text": `); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected bad request %d: %s", w.Code, w.Body.String())
+	}
+	if w := serve("text/csv", "a,b"); w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected unsupported media type %d: %s", w.Code, w.Body.String())
+	}
+
+	// Chunked body of unknown length over the limit, exceeded in part headers
+	buf.Reset()
+	mw = multipart.NewWriter(buf)
+	mw.WriteField("name", "John")
+	mw.CreateFormFile("avatar", strings.Repeat("a", 2048)+".png")
+	mw.Close()
+	r, _ := http.NewRequest("POST", "/", ioutil.NopCloser(buf))
+	r.ContentLength = -1
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	h.ServeHTTPC(components.NewTemplateContext(context.Background(), template.Context{}), w, r)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected too large error %d: %s", w.Code, w.Body.String())
+	}
+}
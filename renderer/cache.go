@@ -0,0 +1,389 @@
+package renderer
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/rs/xhandler"
+	"github.com/rs/xmux"
+	"golang.org/x/net/context"
+
+	"tower.pro/renderer/components"
+	"tower.pro/renderer/helpers"
+	"tower.pro/renderer/middlewares"
+	"tower.pro/renderer/sessions"
+	"tower.pro/renderer/template"
+)
+
+// Cache - Route caching policy.
+type Cache struct {
+	// Control - `Cache-Control` response header, eq. `public, max-age=60`.
+	Control string `json:"control,omitempty" yaml:"control,omitempty"`
+
+	// TTL - Time rendered responses are fresh in in-process cache.
+	// Responses are not stored when empty.
+	TTL time.Duration `json:"ttl,omitempty" yaml:"ttl,omitempty"`
+
+	// Stale - Time after `TTL` when stale response is served
+	// while it's revalidated in background.
+	Stale time.Duration `json:"stale,omitempty" yaml:"stale,omitempty"`
+
+	// Vary - Request values responses vary by.
+	Vary *CacheVary `json:"vary,omitempty" yaml:"vary,omitempty"`
+
+	// Key - Cache key template, it should be unique across routes.
+	// Request host and path are used by default.
+	Key string `json:"key,omitempty" yaml:"key,omitempty"`
+}
+
+// CacheVary - Request values cached responses vary by.
+type CacheVary struct {
+	// Headers - Request headers, also written in `Vary` response header.
+	Headers []string `json:"headers,omitempty" yaml:"headers,omitempty"`
+
+	// Query - Query parameters. When empty and there is no key template
+	// responses vary by whole query.
+	Query []string `json:"query,omitempty" yaml:"query,omitempty"`
+
+	// Params - Route parameters.
+	Params []string `json:"params,omitempty" yaml:"params,omitempty"`
+}
+
+// CacheStore - In-process store of rendered responses.
+// It can be shared by routes and flushed when components change.
+type CacheStore struct {
+	cache *cache.Cache
+	now   func() time.Time
+
+	mutex        sync.Mutex
+	revalidating map[string]bool
+}
+
+// cacheEntry - Cached response.
+type cacheEntry struct {
+	header  http.Header
+	body    []byte
+	created time.Time
+	expires time.Time
+}
+
+// NewCacheStore - Creates a new rendered responses store.
+func NewCacheStore() *CacheStore {
+	return &CacheStore{
+		cache:        cache.New(cache.NoExpiration, time.Minute),
+		now:          time.Now,
+		revalidating: make(map[string]bool),
+	}
+}
+
+// FlushCache - Flushes all stored responses.
+func (s *CacheStore) FlushCache() {
+	s.cache.Flush()
+}
+
+// get - Returns stored response and true if it's fresh.
+func (s *CacheStore) get(key string) (*cacheEntry, bool) {
+	v, ok := s.cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+	entry := v.(*cacheEntry)
+	return entry, s.now().Before(entry.expires)
+}
+
+// set - Stores response if it's successful, wasn't aborted and doesn't set cookies.
+func (s *CacheStore) set(key string, rec *cacheRecorder, policy *Cache) {
+	if rec.status != http.StatusOK || rec.aborted || rec.cookies {
+		return
+	}
+	now := s.now()
+	s.cache.Set(key, &cacheEntry{
+		header:  rec.header,
+		body:    rec.body.Bytes(),
+		created: now,
+		expires: now.Add(policy.TTL),
+	}, policy.TTL+policy.Stale)
+}
+
+// revalidate - Calls `fn` in background unless key is already revalidated.
+func (s *CacheStore) revalidate(key string, fn func()) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.revalidating[key] {
+		return
+	}
+	s.revalidating[key] = true
+	go func() {
+		defer func() {
+			s.mutex.Lock()
+			delete(s.revalidating, key)
+			s.mutex.Unlock()
+		}()
+		fn()
+	}()
+}
+
+// write - Writes cached response. Responds with `304 Not Modified`
+// if request validators match.
+func (entry *cacheEntry) write(w http.ResponseWriter, r *http.Request, status string, now time.Time) {
+	for key, values := range entry.header {
+		w.Header()[key] = append([]string(nil), values...)
+	}
+	w.Header().Set("Age", strconv.Itoa(int(now.Sub(entry.created).Seconds())))
+	w.Header().Set("X-Render-Cache", status)
+	if notModified(w, r) {
+		writeNotModified(w)
+		return
+	}
+	w.Write(entry.body)
+}
+
+// cacheMiddleware - Sets cache headers of route responses and serves
+// responses from cache when they are stored. It's used after route
+// middlewares, so they are never skipped and their values can be used
+// in cache key template.
+func cacheMiddleware(o *webOptions) middlewares.Handler {
+	policy := o.cache
+	store := o.cacheStore
+	if store == nil {
+		store = NewCacheStore()
+	}
+	vary := policy.Vary
+	if vary == nil {
+		vary = new(CacheVary)
+	}
+	return middlewares.ToHandler(func(ctx context.Context, w http.ResponseWriter, r *http.Request, next xhandler.HandlerC) {
+		if policy.Control != "" {
+			w = helpers.WithFlusher(&cacheControlWriter{ResponseWriter: w, control: policy.Control}, w, nil)
+		}
+		for _, name := range vary.Headers {
+			w.Header().Add("Vary", name)
+		}
+		if policy.TTL == 0 || !conditionalMethod(r) || !sessionCacheable(ctx) {
+			next.ServeHTTPC(ctx, w, r)
+			return
+		}
+
+		key, err := cacheKey(ctx, r, o.cacheKey, vary)
+		if err != nil {
+			helpers.WriteError(w, r, http.StatusExpectationFailed, fmt.Sprintf("cache key error: %v", err))
+			return
+		}
+		if o.compression && acceptsGzip(r) {
+			key += "\ngzip"
+		}
+
+		// Serve from cache and revalidate in background if stale
+		if entry, fresh := store.get(key); entry != nil {
+			if fresh {
+				entry.write(w, r, "HIT", store.now())
+				return
+			}
+			store.revalidate(key, func() {
+				ctx, cancel := context.WithTimeout(detachedContext{ctx}, o.reqTimeout)
+				defer cancel()
+				t, _ := components.TemplateContext(ctx)
+				ctx = components.NewTemplateContext(ctx, t.Clone())
+				rec := &cacheRecorder{header: make(http.Header)}
+				next.ServeHTTPC(context.WithValue(ctx, cacheRecorderKey, rec), rec, unconditionalRequest(r))
+				store.set(key, rec, policy)
+			})
+			entry.write(w, r, "STALE", store.now())
+			return
+		}
+
+		w.Header().Set("X-Render-Cache", "MISS")
+		rec := &cacheRecorder{w: w}
+		next.ServeHTTPC(context.WithValue(ctx, cacheRecorderKey, rec), helpers.WithFlusher(rec, w, nil), r)
+		if sessionCacheable(ctx) {
+			store.set(key, rec, policy)
+		}
+	})
+}
+
+type cacheRecorderCtxKey struct{}
+
+var cacheRecorderKey = cacheRecorderCtxKey{}
+
+// abortCache - Marks response as aborted so it's not stored in cache,
+// eq. when streamed response fails after its status was written.
+func abortCache(ctx context.Context) {
+	if rec, ok := ctx.Value(cacheRecorderKey).(*cacheRecorder); ok {
+		rec.aborted = true
+	}
+}
+
+// cacheControlWriter - Response writer setting `Cache-Control` header
+// only on successful and not modified responses, so error pages are not
+// stored by shared caches.
+type cacheControlWriter struct {
+	http.ResponseWriter
+	control string
+	written bool
+}
+
+func (cw *cacheControlWriter) WriteHeader(code int) {
+	if !cw.written {
+		cw.written = true
+		if (code >= 200 && code < 300) || code == http.StatusNotModified {
+			cw.Header().Set("Cache-Control", cw.control)
+		}
+	}
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *cacheControlWriter) Write(body []byte) (int, error) {
+	if !cw.written {
+		cw.WriteHeader(http.StatusOK)
+	}
+	return cw.ResponseWriter.Write(body)
+}
+
+// sessionCacheable - Returns false if request session is not empty
+// or was modified. Responses in such session can be personalized
+// and are neither stored nor served from cache.
+func sessionCacheable(ctx context.Context) bool {
+	s, ok := sessions.FromContext(ctx)
+	return !ok || (len(s.Values) == 0 && !s.Modified())
+}
+
+// cacheKey - Returns cache key of a request. Key template is executed
+// with template context extended with `request` and `params`.
+func cacheKey(ctx context.Context, r *http.Request, keyTemplate template.Template, vary *CacheVary) (string, error) {
+	key := r.Host + r.URL.Path
+	if keyTemplate != nil {
+		t, _ := components.TemplateContext(ctx)
+		t = t.Clone()
+		t["request"] = r
+		t["params"] = xmux.Params(ctx)
+		var err error
+		key, err = template.ExecuteToString(keyTemplate, t)
+		if err != nil {
+			return "", err
+		}
+	}
+	parts := []string{key}
+	if wr, ok := ctx.Value(writerKey).(*Writer); ok {
+		parts = append(parts, wr.MediaType())
+	}
+	for _, name := range vary.Headers {
+		parts = append(parts, "header:"+name+"="+r.Header.Get(name))
+	}
+	query := r.URL.Query()
+	if len(vary.Query) == 0 && keyTemplate == nil {
+		parts = append(parts, "query:"+query.Encode())
+	}
+	for _, name := range vary.Query {
+		parts = append(parts, "query:"+name+"="+strings.Join(query[name], ","))
+	}
+	params := xmux.Params(ctx)
+	for _, name := range vary.Params {
+		parts = append(parts, "param:"+name+"="+params.Get(name))
+	}
+	return strings.Join(parts, "\n"), nil
+}
+
+// unconditionalRequest - Returns copy of a request without validators,
+// so full response is rendered when revalidating.
+func unconditionalRequest(r *http.Request) *http.Request {
+	req := *r
+	req.Header = make(http.Header, len(r.Header))
+	for key, values := range r.Header {
+		req.Header[key] = values
+	}
+	req.Header.Del("If-None-Match")
+	req.Header.Del("If-Modified-Since")
+	return &req
+}
+
+// detachedContext - Context with values of a request context
+// which is not canceled when request is finished.
+type detachedContext struct {
+	values context.Context
+}
+
+func (ctx detachedContext) Deadline() (time.Time, bool)       { return time.Time{}, false }
+func (ctx detachedContext) Done() <-chan struct{}             { return nil }
+func (ctx detachedContext) Err() error                        { return nil }
+func (ctx detachedContext) Value(key interface{}) interface{} { return ctx.values.Value(key) }
+
+// cacheRecorder - Records response written to underlying response writer.
+// Response is only recorded when there is no underlying writer.
+// Recorded headers never contain hop-by-hop headers and `Set-Cookie`,
+// responses setting cookies are marked and not stored.
+type cacheRecorder struct {
+	w       http.ResponseWriter
+	header  http.Header
+	status  int
+	body    bytes.Buffer
+	cookies bool
+	aborted bool
+}
+
+func (rec *cacheRecorder) Header() http.Header {
+	if rec.w != nil {
+		return rec.w.Header()
+	}
+	return rec.header
+}
+
+func (rec *cacheRecorder) WriteHeader(code int) {
+	if rec.status != 0 {
+		return
+	}
+	rec.status = code
+	header := rec.Header()
+	skip := hopHeaders(header)
+	rec.header = make(http.Header, len(header))
+	for key, values := range header {
+		switch {
+		case key == "Set-Cookie":
+			rec.cookies = true
+		case key != "X-Render-Cache" && !skip[key]:
+			rec.header[key] = values
+		}
+	}
+	if rec.w != nil {
+		rec.w.WriteHeader(code)
+	}
+}
+
+// hopHeaders - Returns hop-by-hop headers of a response,
+// including headers listed in `Connection` header.
+func hopHeaders(header http.Header) map[string]bool {
+	hop := map[string]bool{
+		"Connection":          true,
+		"Keep-Alive":          true,
+		"Proxy-Authenticate":  true,
+		"Proxy-Authorization": true,
+		"Te":                  true,
+		"Trailer":             true,
+		"Transfer-Encoding":   true,
+		"Upgrade":             true,
+	}
+	for _, value := range header["Connection"] {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				hop[http.CanonicalHeaderKey(name)] = true
+			}
+		}
+	}
+	return hop
+}
+
+func (rec *cacheRecorder) Write(body []byte) (int, error) {
+	if rec.status == 0 {
+		rec.WriteHeader(http.StatusOK)
+	}
+	rec.body.Write(body)
+	if rec.w != nil {
+		return rec.w.Write(body)
+	}
+	return len(body), nil
+}
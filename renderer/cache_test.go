@@ -0,0 +1,194 @@
+package renderer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/xhandler"
+	"golang.org/x/net/context"
+
+	"tower.pro/renderer/compiler"
+	"tower.pro/renderer/components"
+	"tower.pro/renderer/helpers"
+	"tower.pro/renderer/sessions"
+	"tower.pro/renderer/storage"
+)
+
+func TestCacheMiddleware(t *testing.T) {
+	var renders int32
+	policy := &Cache{
+		Control: "public, max-age=60",
+		TTL:     time.Minute,
+		Stale:   time.Minute,
+		Vary:    &CacheVary{Headers: []string{"X-Lang"}},
+	}
+	now := time.Now()
+	store := NewCacheStore()
+	store.now = func() time.Time { return now }
+	o := constructOpts(WithCache(policy, nil), WithCacheStore(store))
+	h := cacheMiddleware(o)(xhandler.HandlerFuncC(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&renders, 1)
+		w.Header().Set("Connection", "X-Hop")
+		w.Header().Set("X-Hop", "1")
+		writeResponse(w, r, "text/html", []byte(fmt.Sprintf("%s %d", r.Header.Get("X-Lang"), n)))
+	}))
+	serve := func(lang string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("GET", "/test?page=1", nil)
+		r.Header.Set("X-Lang", lang)
+		w := httptest.NewRecorder()
+		h.ServeHTTPC(context.Background(), w, r)
+		return w
+	}
+
+	expect := func(w *httptest.ResponseRecorder, status, body string) {
+		if w.Header().Get("X-Render-Cache") != status || w.Body.String() != body {
+			t.Errorf("Expected %s %q, got %s %q", status, body, w.Header().Get("X-Render-Cache"), w.Body.String())
+		}
+		if w.Header().Get("Cache-Control") != policy.Control || w.Header().Get("Vary") != "X-Lang" {
+			t.Errorf("Invalid cache headers: %v", w.Header())
+		}
+	}
+	expect(serve("en"), "MISS", "en 1")
+	w := serve("en")
+	expect(w, "HIT", "en 1")
+	if w.Header().Get("Connection") != "" || w.Header().Get("X-Hop") != "" {
+		t.Errorf("Hop-by-hop headers were cached: %v", w.Header())
+	}
+	expect(serve("pl"), "MISS", "pl 2")
+
+	// Serve stale response and revalidate in background
+	now = now.Add(2 * time.Minute)
+	expect(serve("en"), "STALE", "en 1")
+	w = serve("en")
+	for i := 0; i < 100 && w.Header().Get("X-Render-Cache") != "HIT"; i++ {
+		time.Sleep(5 * time.Millisecond)
+		w = serve("en")
+	}
+	expect(w, "HIT", "en 3")
+
+	store.FlushCache()
+	expect(serve("en"), "MISS", "en 4")
+}
+
+func TestCacheSessions(t *testing.T) {
+	var renders int32
+	store, _ := sessions.New([][]byte{[]byte("0123456789abcdef")})
+	o := constructOpts(WithCache(&Cache{TTL: time.Minute}, nil))
+	h := sessions.Middleware(store)(cacheMiddleware(o)(xhandler.HandlerFuncC(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Login") != "" {
+			s, _ := sessions.FromContext(ctx)
+			s.Set("user", "john")
+		}
+		n := atomic.AddInt32(&renders, 1)
+		writeResponse(w, r, "text/html", []byte(fmt.Sprintf("%d", n)))
+	})))
+	serve := func(login bool, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("GET", "/test", nil)
+		if login {
+			r.Header.Set("X-Login", "1")
+		}
+		for _, cookie := range cookies {
+			r.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTPC(context.Background(), w, r)
+		return w
+	}
+
+	w := serve(true)
+	cookies := (&http.Response{Header: w.Header()}).Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("Expected session cookie, got %v", w.Header())
+	}
+
+	// Other clients never get session cookie or personalized response
+	for i, body := range []string{"2", "2"} {
+		w = serve(false)
+		if w.Header().Get("Set-Cookie") != "" || w.Body.String() != body {
+			t.Errorf("%d: unexpected response %v %q", i, w.Header(), w.Body.String())
+		}
+	}
+
+	// Responses in non-empty session are not served from cache
+	w = serve(false, cookies[0])
+	if w.Header().Get("X-Render-Cache") != "" || w.Body.String() != "3" {
+		t.Errorf("Unexpected session response %v %q", w.Header(), w.Body.String())
+	}
+}
+
+func TestCacheStreamError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "renderer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, def := range map[string]string{
+		"root": "main: template://<html><head><title>x</title></head><body>{{ children }}</body></html>",
+		"page": "main: template://{{ widget|bool }}\nextends: site.root\ncontext:\n  widget: 1",
+	} {
+		os.MkdirAll(filepath.Join(dir, "site", name), 0755)
+		if err = ioutil.WriteFile(filepath.Join(dir, "site", name, "component.yaml"), []byte(def), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s, err := storage.New(storage.WithDir(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := compiler.NewContext(context.Background(), compiler.New(s))
+
+	handler := &Handler{
+		Component: &components.Component{Name: "site.page"},
+		Stream:    true,
+		Cache:     &Cache{TTL: time.Minute},
+	}
+	h, err := handler.Construct()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		r, _ := http.NewRequest("GET", "/", nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTPC(ctx, w, r)
+		if cache := w.Header().Get("X-Render-Cache"); cache != "MISS" || !strings.HasSuffix(w.Body.String(), "<!--renderer:error-->") {
+			t.Errorf("%d: expected failed stream not served from cache, got %q: %s", i, cache, w.Body.String())
+		}
+	}
+}
+
+func TestCacheControlErrors(t *testing.T) {
+	o := constructOpts(WithCache(&Cache{Control: "public, max-age=600"}, nil))
+	h := cacheMiddleware(o)(xhandler.HandlerFuncC(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/error" {
+			helpers.WriteError(w, r, http.StatusExpectationFailed, "render error")
+			return
+		}
+		writeResponse(w, r, "text/html", []byte("ok"))
+	}))
+	serve := func(path, etag string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("GET", path, nil)
+		r.Header.Set("If-None-Match", etag)
+		w := httptest.NewRecorder()
+		h.ServeHTTPC(context.Background(), w, r)
+		return w
+	}
+
+	w := serve("/", "")
+	if w.Code != http.StatusOK || w.Header().Get("Cache-Control") != "public, max-age=600" {
+		t.Errorf("Expected Cache-Control in %d response: %v", w.Code, w.Header())
+	}
+	if w = serve("/", w.Header().Get("ETag")); w.Code != http.StatusNotModified || w.Header().Get("Cache-Control") == "" {
+		t.Errorf("Expected Cache-Control in %d response: %v", w.Code, w.Header())
+	}
+	if w = serve("/error", ""); w.Code != http.StatusExpectationFailed || w.Header().Get("Cache-Control") != "" {
+		t.Errorf("Unexpected Cache-Control in %d response: %v", w.Code, w.Header())
+	}
+}
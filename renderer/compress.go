@@ -0,0 +1,175 @@
+package renderer
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/rs/xhandler"
+	"golang.org/x/net/context"
+
+	"tower.pro/renderer/helpers"
+	"tower.pro/renderer/middlewares"
+)
+
+var gzipWriters = sync.Pool{
+	New: func() interface{} { return gzip.NewWriter(nil) },
+}
+
+// compressMiddleware - Compresses responses with gzip when accepted by client.
+// Responses smaller than minimum size are not compressed.
+func compressMiddleware(minSize int) middlewares.Handler {
+	return middlewares.ToHandler(func(ctx context.Context, w http.ResponseWriter, r *http.Request, next xhandler.HandlerC) {
+		addVary(w.Header(), "Accept-Encoding")
+		if !acceptsGzip(r) {
+			next.ServeHTTPC(ctx, w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, minSize: minSize}
+		if ew, ok := ctx.Value(errorWriterKey).(*errorWriter); ok {
+			cw.captured = ew.captures
+		}
+		defer cw.Close()
+		next.ServeHTTPC(ctx, helpers.WithFlusher(cw, w, cw.flush), r)
+	})
+}
+
+// addVary - Adds header name to `Vary` header unless it's already there.
+func addVary(header http.Header, name string) {
+	for _, value := range header["Vary"] {
+		for _, v := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), name) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", name)
+}
+
+// acceptsGzip - Returns true if gzip is accepted in `Accept-Encoding` header.
+func acceptsGzip(r *http.Request) bool {
+	header := r.Header.Get("Accept-Encoding")
+	if header == "" {
+		return false
+	}
+	q := -1.0
+	for _, m := range helpers.ParseAccept(header) {
+		if m.Type == "gzip" {
+			return m.Q > 0
+		}
+		if m.Type == "*/*" {
+			q = m.Q
+		}
+	}
+	return q > 0
+}
+
+// compressWriter - Response writer buffering body until minimum size is reached,
+// then compressing it if response is compressible.
+type compressWriter struct {
+	http.ResponseWriter
+	minSize int
+
+	// captured - Returns true if response with status code is replaced
+	// with error page, which is compressed separately.
+	captured func(int) bool
+
+	status  int
+	buf     []byte
+	started bool
+	gz      *gzip.Writer
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.started {
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	cw.status = code
+}
+
+func (cw *compressWriter) Write(body []byte) (int, error) {
+	if !cw.started {
+		cw.buf = append(cw.buf, body...)
+		if len(cw.buf) >= cw.minSize {
+			return len(body), cw.start(true)
+		}
+		return len(body), nil
+	}
+	if cw.gz != nil {
+		return cw.gz.Write(body)
+	}
+	return cw.ResponseWriter.Write(body)
+}
+
+// flush - Starts writing response compressed and flushes it.
+func (cw *compressWriter) flush(f http.Flusher) {
+	if !cw.started {
+		cw.start(true)
+	}
+	if cw.gz != nil {
+		cw.gz.Flush()
+	}
+	f.Flush()
+}
+
+// Close - Writes buffered response and finishes compression.
+func (cw *compressWriter) Close() error {
+	if !cw.started {
+		if err := cw.start(false); err != nil {
+			return err
+		}
+	}
+	if cw.gz == nil {
+		return nil
+	}
+	err := cw.gz.Close()
+	cw.gz.Reset(nil)
+	gzipWriters.Put(cw.gz)
+	cw.gz = nil
+	return err
+}
+
+// start - Writes response header and buffered body.
+// Compresses response if `compress` is true and response is compressible.
+// Strong `ETag` is turned into weak one when compressed.
+func (cw *compressWriter) start(compress bool) (err error) {
+	cw.started = true
+	header := cw.Header()
+	if compress && cw.compressible() {
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			header.Set("ETag", "W/"+etag)
+		}
+		cw.gz = gzipWriters.Get().(*gzip.Writer)
+		cw.gz.Reset(cw.ResponseWriter)
+	}
+	if cw.status != 0 {
+		cw.ResponseWriter.WriteHeader(cw.status)
+	}
+	if len(cw.buf) == 0 {
+		return
+	}
+	if cw.gz != nil {
+		_, err = cw.gz.Write(cw.buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(cw.buf)
+	}
+	cw.buf = nil
+	return
+}
+
+// compressible - Returns true if response has a body, is not replaced with error page,
+// is not a range of content, is not encoded yet and has a text content type.
+func (cw *compressWriter) compressible() bool {
+	if cw.status == http.StatusNoContent || cw.status == http.StatusNotModified ||
+		cw.status == http.StatusPartialContent || cw.Header().Get("Content-Range") != "" ||
+		(cw.captured != nil && cw.captured(cw.status)) || cw.Header().Get("Content-Encoding") != "" {
+		return false
+	}
+	typ := cw.Header().Get("Content-Type")
+	return strings.HasPrefix(typ, "text/") || strings.Contains(typ, "json") ||
+		strings.Contains(typ, "yaml") || strings.Contains(typ, "xml") || strings.Contains(typ, "javascript")
+}
@@ -0,0 +1,88 @@
+package renderer
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/xhandler"
+	"golang.org/x/net/context"
+)
+
+func TestCompressMiddleware(t *testing.T) {
+	renders := 0
+	o := constructOpts(WithCache(&Cache{TTL: time.Minute}, nil), WithCompression(10))
+	h := cacheMiddleware(o)(compressMiddleware(o.compressMin)(xhandler.HandlerFuncC(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		renders++
+		writeResponse(w, r, "text/html", []byte(r.URL.Query().Get("body")))
+	})))
+	serve := func(body, encoding string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("GET", "/?body="+body, nil)
+		r.Header.Set("Accept-Encoding", encoding)
+		w := httptest.NewRecorder()
+		h.ServeHTTPC(context.Background(), w, r)
+		return w
+	}
+	gunzip := func(w *httptest.ResponseRecorder) string {
+		if w.Header().Get("Content-Encoding") != "gzip" || !strings.HasPrefix(w.Header().Get("ETag"), `W/"`) {
+			t.Fatalf("Response not compressed: %v", w.Header())
+		}
+		gz, err := gzip.NewReader(w.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(gz)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(body)
+	}
+
+	long := strings.Repeat("test", 10)
+	if body := gunzip(serve(long, "deflate, gzip")); body != long {
+		t.Errorf("Invalid body: %q", body)
+	}
+	if body := gunzip(serve(long, "gzip")); body != long || renders != 1 {
+		t.Errorf("Invalid cached body %q after %d renders", body, renders)
+	}
+	if w := serve(long, "gzip;q=0, *"); w.Header().Get("Content-Encoding") != "" || w.Body.String() != long {
+		t.Errorf("Expected uncompressed response, got: %v", w.Header())
+	}
+	if w := serve("short", "gzip"); w.Header().Get("Content-Encoding") != "" || w.Body.String() != "short" {
+		t.Errorf("Expected uncompressed short response, got: %v", w.Header())
+	}
+	if w := serve(long, "gzip"); w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("Invalid Vary header: %v", w.Header())
+	}
+}
+
+func TestCompressMiddlewareFlusher(t *testing.T) {
+	var flusher bool
+	h := compressMiddleware(10)(xhandler.HandlerFuncC(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("head"))
+		var f http.Flusher
+		if f, flusher = w.(http.Flusher); flusher {
+			f.Flush()
+		}
+	}))
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+
+	w := httptest.NewRecorder()
+	h.ServeHTTPC(context.Background(), w, r)
+	if !flusher || !w.Flushed || w.Header().Get("Content-Encoding") != "gzip" {
+		t.Errorf("Expected flushed compressed response, got %v: %v", w.Flushed, w.Header())
+	}
+
+	// Response writer which can't be flushed
+	w = httptest.NewRecorder()
+	h.ServeHTTPC(context.Background(), struct{ http.ResponseWriter }{w}, r)
+	if flusher || w.Body.String() != "head" {
+		t.Errorf("Expected response writer without Flush, got %q", w.Body.String())
+	}
+}
@@ -0,0 +1,121 @@
+package renderer
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/xhandler"
+	"golang.org/x/net/context"
+
+	"tower.pro/renderer/components"
+	"tower.pro/renderer/helpers"
+	"tower.pro/renderer/template"
+)
+
+// ConditionalInContext - Sets `ETag` computed from key template and compiled
+// components tree hash if key is set in options and `Last-Modified` from
+// storage files modification time if enabled in options.
+// Responds with `304 Not Modified` before rendering if request validators match.
+func ConditionalInContext(next xhandler.HandlerC) xhandler.HandlerC {
+	return xhandler.HandlerFuncC(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		o := optionsFromContext(ctx)
+		if (o.etag == nil && !o.lastModified) || !conditionalMethod(r) {
+			next.ServeHTTPC(ctx, w, r)
+			return
+		}
+		c, ok := components.CompiledFromContext(ctx)
+		if !ok {
+			helpers.WriteError(w, r, http.StatusBadRequest, "component not compiled")
+			return
+		}
+		if o.etag != nil {
+			t, _ := components.TemplateContext(ctx)
+			key, err := template.ExecuteToString(o.etag, t)
+			if err != nil {
+				helpers.WriteError(w, r, http.StatusExpectationFailed, fmt.Sprintf("etag error: %v", err))
+				return
+			}
+			var typ string
+			if wr, ok := ctx.Value(writerKey).(*Writer); ok {
+				typ = wr.MediaType()
+			}
+			w.Header().Set("ETag", strongETag([]byte(key+"\n"+c.Hash()+"\n"+typ)))
+		}
+		if o.lastModified && !c.ModTime.IsZero() {
+			w.Header().Set("Last-Modified", c.ModTime.UTC().Format(http.TimeFormat))
+		}
+		if notModified(w, r) {
+			writeNotModified(w)
+			return
+		}
+		next.ServeHTTPC(ctx, w, r)
+	})
+}
+
+// writeResponse - Writes response body with content type. On `GET` and `HEAD`
+// requests sets strong `ETag` computed from body if not set yet and responds
+// with `304 Not Modified` if request validators match.
+func writeResponse(w http.ResponseWriter, r *http.Request, typ string, body []byte) {
+	w.Header().Set("Content-Type", typ)
+	if conditionalMethod(r) {
+		if w.Header().Get("ETag") == "" {
+			w.Header().Set("ETag", strongETag(body))
+		}
+		if notModified(w, r) {
+			writeNotModified(w)
+			return
+		}
+	}
+	w.Write(body)
+}
+
+func writeNotModified(w http.ResponseWriter) {
+	w.Header().Del("Content-Type")
+	w.WriteHeader(http.StatusNotModified)
+}
+
+func conditionalMethod(r *http.Request) bool {
+	return r.Method == "GET" || r.Method == "HEAD"
+}
+
+// notModified - Returns true if `If-None-Match` matches `ETag` response header
+// or, when there is no `If-None-Match`, `Last-Modified` response header
+// is not after `If-Modified-Since`.
+func notModified(w http.ResponseWriter, r *http.Request) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		etag := w.Header().Get("ETag")
+		return etag != "" && etagMatch(match, etag)
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(w.Header().Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+	return !modified.Truncate(time.Second).After(since)
+}
+
+// etagMatch - Returns true if `If-None-Match` header value matches etag.
+// Uses weak comparison as required for `If-None-Match`.
+func etagMatch(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, value := range strings.Split(header, ",") {
+		value = strings.TrimSpace(value)
+		if value == "*" || strings.TrimPrefix(value, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// strongETag - Returns strong entity tag of a body.
+func strongETag(body []byte) string {
+	sum := sha1.Sum(body)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
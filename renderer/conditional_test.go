@@ -0,0 +1,79 @@
+package renderer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rs/xhandler"
+	"golang.org/x/net/context"
+
+	"tower.pro/renderer/components"
+	"tower.pro/renderer/template"
+)
+
+func TestWriteResponse(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	writeResponse(w, r, "text/html", []byte("<p>test</p>"))
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" || w.Body.String() != "<p>test</p>" {
+		t.Fatalf("Invalid response %d %q: %s", w.Code, etag, w.Body.String())
+	}
+
+	r.Header.Set("If-None-Match", `"other", `+etag)
+	w = httptest.NewRecorder()
+	writeResponse(w, r, "text/html", []byte("<p>test</p>"))
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("Expected not modified response, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestConditionalInContext(t *testing.T) {
+	key, err := template.FromString("{{ id }}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)
+	c := &components.Compiled{Component: &components.Component{Name: "test"}, ModTime: modTime}
+
+	rendered := false
+	h := optionsMiddleware(constructOpts(WithETag(key), WithLastModified()))(ConditionalInContext(
+		xhandler.HandlerFuncC(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			rendered = true
+		})))
+	serve := func(header, value string) *httptest.ResponseRecorder {
+		ctx := components.NewCompiledContext(context.Background(), c)
+		ctx = components.NewTemplateContext(ctx, template.Context{"id": 1})
+		r, _ := http.NewRequest("GET", "/", nil)
+		if header != "" {
+			r.Header.Set(header, value)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTPC(ctx, w, r)
+		return w
+	}
+
+	w := serve("", "")
+	etag := w.Header().Get("ETag")
+	if !rendered || etag == "" || w.Header().Get("Last-Modified") != modTime.Format(http.TimeFormat) {
+		t.Fatalf("Invalid headers: %v", w.Header())
+	}
+
+	rendered = false
+	w = serve("If-None-Match", etag)
+	if rendered || w.Code != http.StatusNotModified {
+		t.Errorf("Expected not modified before render, got %d", w.Code)
+	}
+
+	w = serve("If-Modified-Since", modTime.Add(time.Hour).Format(http.TimeFormat))
+	if rendered || w.Code != http.StatusNotModified {
+		t.Errorf("Expected not modified since, got %d", w.Code)
+	}
+
+	w = serve("If-Modified-Since", modTime.Add(-time.Hour).Format(http.TimeFormat))
+	if !rendered {
+		t.Errorf("Expected render when modified, got %d", w.Code)
+	}
+}
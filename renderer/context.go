@@ -2,12 +2,13 @@ package renderer
 
 import (
 	"net/http"
-	"strconv"
 	"strings"
 
 	"github.com/rs/xmux"
 
 	"golang.org/x/net/context"
+
+	"tower.pro/renderer/helpers"
 )
 
 type requestContext struct {
@@ -26,13 +27,22 @@ type requestContext struct {
 //   * `request.header.{key}` - Request Header value (string)
 //   * `request.form` - Request Form (url.Values)
 //   * `request.form.{key}` - Request Form value (string)
+//   * `request.cookie` - Request Cookies ([]*http.Cookie)
+//   * `request.cookie.{name}` - Request Cookie value (string)
 //   * `request.url` - Request URL (*url.URL)
-//   * `request.url.host` - Request URL Host (*url.URL)
+//   * `request.url.host` - Request URL Host (string)
+//   * `request.url.path` - Request URL Path (string)
 //   * `request.url.query` - Request URL Query (url.Values)
 //   * `request.url.query.{key}` - Request URL Query value (string)
 //   * `request.url.params` - Request URL Parameters (xmux.ParamHolder)
 //   * `request.url.params.{key}` - Request URL Parameter value (string)
 //
+// Keys of values can have a type and a default in format `{key}:{type}={default}`,
+// eq. `request.url.query.page:int=1`. Types are `string`, `int`, `int64`, `float`,
+// `bool`, `duration`, `json` and `list` (see `helpers.ParseValue`). Typed value
+// which cannot be converted is a `*helpers.ValueError`. All values of a key
+// are returned as a list ([]interface{}) if key ends with `[]`, eq. `request.url.query.tag[]`.
+//
 func NewRequestContext(ctx context.Context, req *http.Request) context.Context {
 	return &requestContext{
 		Context: ctx,
@@ -71,6 +81,8 @@ func (ctx *requestContext) Value(key interface{}) interface{} {
 		return ctx.getFromHeader(split[2:]...)
 	case "form":
 		return ctx.getFromForm(split[2:]...)
+	case "cookie":
+		return ctx.getFromCookie(split[2:]...)
 	}
 
 	return ctx.Context.Value(key)
@@ -86,49 +98,96 @@ func (ctx *requestContext) getFromURL(key interface{}, rest ...string) interface
 	case "path":
 		return ctx.Request.URL.Path
 	case "query":
-		if len(rest) == 0 {
+		if len(rest) == 1 {
 			return ctx.Request.URL.Query()
 		}
-		return ctx.Request.URL.Query().Get(strings.Join(rest[1:], "."))
+		return lookupValue(strings.Join(rest[1:], "."), func(name string) []string {
+			return ctx.Request.URL.Query()[name]
+		})
 	case "params":
-		if len(rest) == 0 {
+		if len(rest) == 1 {
 			return xmux.Params(ctx.Context)
 		}
-		return xmux.Params(ctx.Context).Get(strings.Join(rest[1:], "."))
+		return lookupValue(strings.Join(rest[1:], "."), func(name string) []string {
+			return []string{xmux.Params(ctx.Context).Get(name)}
+		})
 	default:
 		return ctx.Context.Value(key)
 	}
 }
 
 func (ctx *requestContext) getFromForm(rest ...string) interface{} {
+	if err := ctx.Request.ParseForm(); err != nil {
+		return nil
+	}
 	if len(rest) == 0 {
 		return ctx.Request.Form
 	}
-	if err := ctx.Request.ParseForm(); err != nil {
-		return nil
+	return lookupValue(strings.Join(rest, "."), func(name string) []string {
+		return ctx.Request.Form[name]
+	})
+}
+
+func (ctx *requestContext) getFromHeader(rest ...string) interface{} {
+	if len(rest) == 0 {
+		return ctx.Request.Header
 	}
-	key := strings.Join(rest, ".")
-	if split := strings.Split(key, ":"); len(split) == 2 {
-		return formatValue(split[1], ctx.Request.Form.Get(split[0]))
+	return lookupValue(strings.Join(rest, "."), func(name string) []string {
+		return ctx.Request.Header[http.CanonicalHeaderKey(name)]
+	})
+}
+
+func (ctx *requestContext) getFromCookie(rest ...string) interface{} {
+	if len(rest) == 0 {
+		return ctx.Request.Cookies()
 	}
-	return ctx.Request.Form.Get(key)
+	return lookupValue(strings.Join(rest, "."), func(name string) (values []string) {
+		for _, cookie := range ctx.Request.Cookies() {
+			if cookie.Name == name {
+				values = append(values, cookie.Value)
+			}
+		}
+		return
+	})
 }
 
-func formatValue(t string, v string) interface{} {
-	switch t {
-	case "int":
-		v, _ := strconv.Atoi(v)
-		return v
-	case "bool":
-		v, _ := strconv.ParseBool(v)
-		return v
+// lookupValue - Looks up request value by key in format `name[:type][=default]`.
+// Values are taken from `get` function, default is used if value is empty.
+// Returns zero value if typed value is empty and `*helpers.ValueError` if conversion fails.
+// Returns list of all values if name ends with `[]`, eq. `tag[]`.
+func lookupValue(key string, get func(name string) []string) interface{} {
+	name, typ, def := helpers.ParseKey(key)
+	if strings.HasSuffix(name, "[]") {
+		return lookupList(key, strings.TrimSuffix(name, "[]"), typ, def, get)
+	}
+	values := get(name)
+	if len(values) == 0 || values[0] == "" {
+		if def != nil {
+			values = []string{*def}
+		} else if typ != "" {
+			return helpers.ZeroValue(typ)
+		}
+	}
+	v, err := helpers.ParseValue(typ, values)
+	if err != nil {
+		return &helpers.ValueError{Key: key, Err: err}
 	}
 	return v
 }
 
-func (ctx *requestContext) getFromHeader(rest ...string) interface{} {
-	if len(rest) == 0 {
-		return ctx.Request.Header
+// lookupList - Looks up list of all request values converted to type.
+func lookupList(key, name, typ string, def *string, get func(name string) []string) interface{} {
+	values := get(name)
+	if len(values) == 0 && def != nil {
+		values = []string{*def}
+	}
+	res := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		v, err := helpers.ParseValue(typ, []string{value})
+		if err != nil {
+			return &helpers.ValueError{Key: key, Err: err}
+		}
+		res = append(res, v)
 	}
-	return ctx.Request.Header.Get(strings.Join(rest, "."))
+	return res
 }
@@ -0,0 +1,78 @@
+package renderer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rs/xhandler"
+	"github.com/rs/xmux"
+	"golang.org/x/net/context"
+
+	"tower.pro/renderer/helpers"
+)
+
+func TestRequestContext(t *testing.T) {
+	var ctx context.Context
+	mux := xmux.New()
+	mux.HandleC("POST", "/products/:id", xhandler.HandlerFuncC(func(c context.Context, w http.ResponseWriter, r *http.Request) {
+		ctx = NewRequestContext(c, r)
+	}))
+
+	r, _ := http.NewRequest("POST", "http://example.com/products/12?tag=a&tag=b&page=2&ids=1&ids=x", strings.NewReader("name=John&age=30"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("X-Test", "test")
+	r.AddCookie(&http.Cookie{Name: "theme", Value: "dark"})
+	r.RemoteAddr = "127.0.0.1:1234"
+	mux.ServeHTTPC(context.Background(), httptest.NewRecorder(), r)
+	if ctx == nil {
+		t.Fatal("Route was not matched")
+	}
+
+	tests := map[string]interface{}{
+		"request":                      r,
+		"request.host":                 "example.com",
+		"request.method":               "POST",
+		"request.remote":               "127.0.0.1:1234",
+		"request.header":               r.Header,
+		"request.header.x-test":        "test",
+		"request.header.X-Missing=def": "def",
+		"request.form":                 url.Values{"name": {"John"}, "age": {"30"}, "tag": {"a", "b"}, "page": {"2"}, "ids": {"1", "x"}},
+		"request.form.name":            "John",
+		"request.form.age:int":         30,
+		"request.form.missing:int":     0,
+		"request.header.x-test:other":  "",
+		"request.cookie":               r.Cookies(),
+		"request.cookie.theme":         "dark",
+		"request.url":                  r.URL,
+		"request.url.host":             "example.com",
+		"request.url.path":             "/products/12",
+		"request.url.query":            url.Values{"tag": {"a", "b"}, "page": {"2"}, "ids": {"1", "x"}},
+		"request.url.query.page":       "2",
+		"request.url.query.page:int":   2,
+		"request.url.query.size:int=5": 5,
+		"request.url.query.missing":    "",
+		"request.url.query.tag[]":      []interface{}{"a", "b"},
+		"request.url.query.none[]":     []interface{}{},
+		"request.url.params":           xmux.Params(ctx),
+		"request.url.params.id":        "12",
+		"request.url.params.id:int":    12,
+	}
+	for key, expected := range tests {
+		if v := ctx.Value(key); !reflect.DeepEqual(v, expected) {
+			t.Errorf("%s: got %#v, expected %#v", key, v, expected)
+		}
+	}
+
+	if len(xmux.Params(ctx)) != 1 {
+		t.Errorf("Invalid params %#v", xmux.Params(ctx))
+	}
+	for _, key := range []string{"request.url.query.tag:int", "request.url.query.ids[]:int"} {
+		if _, ok := ctx.Value(key).(*helpers.ValueError); !ok {
+			t.Errorf("%s: expected value error, got %#v", key, ctx.Value(key))
+		}
+	}
+}
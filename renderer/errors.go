@@ -0,0 +1,168 @@
+package renderer
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/rs/xhandler"
+	"github.com/rs/xmux"
+	"golang.org/x/net/context"
+
+	"tower.pro/renderer/compiler"
+	"tower.pro/renderer/components"
+	"tower.pro/renderer/helpers"
+	"tower.pro/renderer/middlewares"
+	"tower.pro/renderer/template"
+)
+
+type errorWriterCtxKey struct{}
+
+var errorWriterKey = errorWriterCtxKey{}
+
+// errorsMiddleware - Renders error pages in place of error responses
+// with status codes set in options, when HTML is accepted by client.
+// Error component is rendered with request template context extended
+// with `error`, `status`, `request` and `params`. If it fails, plain text
+// status is written. Error pages are compressed when compression is enabled.
+func errorsMiddleware(o *webOptions) middlewares.Handler {
+	return middlewares.ToHandler(func(ctx context.Context, w http.ResponseWriter, r *http.Request, next xhandler.HandlerC) {
+		if !acceptsHTML(r) {
+			next.ServeHTTPC(ctx, w, r)
+			return
+		}
+		ew := &errorWriter{ResponseWriter: w, pages: o.errors}
+		next.ServeHTTPC(context.WithValue(ctx, errorWriterKey, ew), helpers.WithFlusher(ew, w, ew.flush), r)
+		if ew.status == 0 {
+			return
+		}
+		body, err := renderErrorPage(ctx, r, o, ew.template, ew.status, strings.TrimSpace(ew.body.String()))
+		w.Header().Del("Content-Length")
+		if o.compression {
+			addVary(w.Header(), "Accept-Encoding")
+			if acceptsGzip(r) {
+				cw := &compressWriter{ResponseWriter: w, minSize: o.compressMin}
+				defer cw.Close()
+				w = cw
+			}
+		}
+		if err != nil {
+			glog.Warningf("[api] error page %d (%s) failed: %v", ew.status, o.errors[ew.status], err)
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(ew.status)
+			fmt.Fprintf(w, "%d %s", ew.status, http.StatusText(ew.status))
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(ew.status)
+		w.Write([]byte(body))
+	})
+}
+
+// errorsTemplateContext - Stores request template context in error writer,
+// so error pages are rendered with values set by following middlewares.
+func errorsTemplateContext(next xhandler.HandlerC) xhandler.HandlerC {
+	return xhandler.HandlerFuncC(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		if ew, ok := ctx.Value(errorWriterKey).(*errorWriter); ok {
+			ew.template, _ = components.TemplateContext(ctx)
+		}
+		next.ServeHTTPC(ctx, w, r)
+	})
+}
+
+// renderErrorPage - Renders error page component with a copy of template context,
+// default template context is used when nil.
+func renderErrorPage(ctx context.Context, r *http.Request, o *webOptions, t template.Context, status int, msg string) (_ string, err error) {
+	comp, ok := compiler.FromContext(ctx)
+	if !ok {
+		return "", fmt.Errorf("compiler not found")
+	}
+	c, err := comp.CompileFromStorage(&components.Component{Name: o.errors[status]})
+	if err != nil {
+		return
+	}
+	if t == nil {
+		t = o.defaultCtx
+	}
+	t = t.Clone()
+	t["error"] = msg
+	t["status"] = status
+	t["request"] = r
+	t["params"] = xmux.Params(ctx)
+	res, err := components.Render(c, t)
+	if err != nil {
+		return
+	}
+	return res.HTML(), nil
+}
+
+// acceptsHTML - Returns true if HTML is accepted in `Accept` header.
+func acceptsHTML(r *http.Request) bool {
+	for _, m := range helpers.ParseAccept(r.Header.Get("Accept")) {
+		if m.Q > 0 && m.Match("text/html") >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// errorWriter - Response writer capturing error responses
+// with status codes which have error pages.
+type errorWriter struct {
+	http.ResponseWriter
+	pages map[int]string
+
+	// status - Captured error status code, zero if not captured.
+	status int
+	body   bytes.Buffer
+
+	// template - Request template context, nil if not set yet.
+	template template.Context
+
+	// written - True if status code was written to underlying writer.
+	written bool
+	// passthrough - True if errors should not be captured.
+	passthrough bool
+}
+
+func (ew *errorWriter) WriteHeader(code int) {
+	if ew.written || ew.status != 0 {
+		return
+	}
+	if ew.captures(code) {
+		ew.status = code
+		return
+	}
+	ew.written = true
+	ew.ResponseWriter.WriteHeader(code)
+}
+
+// captures - Returns true if response with status code is replaced with error page.
+func (ew *errorWriter) captures(code int) bool {
+	_, ok := ew.pages[code]
+	return ok && !ew.passthrough
+}
+
+func (ew *errorWriter) Write(body []byte) (int, error) {
+	if ew.status != 0 {
+		return ew.body.Write(body)
+	}
+	ew.written = true
+	return ew.ResponseWriter.Write(body)
+}
+
+// flush - Flushes response unless it's replaced with error page.
+func (ew *errorWriter) flush(f http.Flusher) {
+	if ew.status == 0 {
+		f.Flush()
+	}
+}
+
+// passErrors - Disables error pages for response, eq. when status is set in route.
+func passErrors(ctx context.Context) {
+	if ew, ok := ctx.Value(errorWriterKey).(*errorWriter); ok {
+		ew.passthrough = true
+	}
+}
@@ -0,0 +1,105 @@
+package renderer
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/xhandler"
+	"golang.org/x/net/context"
+
+	"tower.pro/renderer/compiler"
+	"tower.pro/renderer/components"
+	"tower.pro/renderer/helpers"
+	"tower.pro/renderer/storage"
+)
+
+func TestErrorsMiddleware(t *testing.T) {
+	dir, err := ioutil.TempDir("", "renderer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "site", "not_found"), 0755)
+	err = ioutil.WriteFile(filepath.Join(dir, "site", "not_found", "component.yaml"),
+		[]byte("main: \"template://<p>{{ status }}: {{ error }}</p>\""), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := storage.New(storage.WithDir(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := constructOpts(WithErrorPage(http.StatusNotFound, "site.not_found"), WithErrorPage(http.StatusInternalServerError, "site.error"))
+	h := errorsMiddleware(o)(xhandler.HandlerFuncC(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		code := http.StatusNotFound
+		if r.URL.Path == "/fail" {
+			code = http.StatusInternalServerError
+		}
+		helpers.WriteError(w, r, code, "product not found")
+	}))
+	serve := func(path, accept string) *httptest.ResponseRecorder {
+		ctx := compiler.NewContext(context.Background(), compiler.New(s))
+		r, _ := http.NewRequest("GET", path, nil)
+		r.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		h.ServeHTTPC(ctx, w, r)
+		return w
+	}
+
+	w := serve("/", "text/html")
+	if w.Code != http.StatusNotFound || w.Body.String() != "<p>404: product not found</p>" {
+		t.Errorf("Invalid error page %d: %s", w.Code, w.Body.String())
+	}
+
+	w = serve("/", "application/json")
+	if w.Code != http.StatusNotFound || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected JSON error %d: %s", w.Code, w.Body.String())
+	}
+
+	w = serve("/fail", "")
+	if w.Code != http.StatusInternalServerError || w.Body.String() != "500 Internal Server Error" {
+		t.Errorf("Expected plain text fallback %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestErrorsMiddlewareCompressed(t *testing.T) {
+	ctx, cleanup := testContext(t, map[string]string{
+		"site.not_found": `main: "template://<p>{{ status }} for {{ user }}: {{ error }}</p>"`,
+	})
+	defer cleanup()
+
+	o := constructOpts(WithErrorPage(http.StatusNotFound, "site.not_found"), WithCompression(10))
+	var chain xhandler.Chain
+	chain.UseC(optionsMiddleware(o))
+	chain.UseC(errorsMiddleware(o))
+	chain.UseC(o.templateCtxSetter)
+	chain.UseC(errorsTemplateContext)
+	chain.UseC(compressMiddleware(o.compressMin))
+	h := chain.HandlerC(xhandler.HandlerFuncC(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		components.WithTemplateKey(ctx, "user", "john")
+		helpers.WriteError(w, r, http.StatusNotFound, "product not found")
+	}))
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept", "text/html")
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	h.ServeHTTPC(ctx, w, r)
+	if w.Code != http.StatusNotFound || w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("Expected compressed error page %d: %v", w.Code, w.Header())
+	}
+	gz, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(gz)
+	if string(body) != "<p>404 for john: product not found</p>" {
+		t.Errorf("Invalid error page: %s", body)
+	}
+}
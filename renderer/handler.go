@@ -1,7 +1,10 @@
 package renderer
 
 import (
+	"fmt"
 	"net/http"
+	"sort"
+	"strings"
 
 	"github.com/rs/xhandler"
 	"github.com/rs/xmux"
@@ -10,22 +13,129 @@ import (
 
 	"tower.pro/renderer/components"
 	"tower.pro/renderer/middlewares"
+	"tower.pro/renderer/template"
 )
 
 // Handler - Web route handler.
 type Handler struct {
 	Component   *components.Component     `json:"component,omitempty" yaml:"component,omitempty"`
 	Middlewares []*middlewares.Middleware `json:"middlewares,omitempty" yaml:"middlewares,omitempty"`
+
+	// Status - Response status code (see `WithStatus`).
+	Status int `json:"status,omitempty" yaml:"status,omitempty"`
+
+	// Headers - Response headers values templates (see `WithHeader`).
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+
+	// Redirect - Redirect target template (see `WithRedirect`).
+	Redirect string `json:"redirect,omitempty" yaml:"redirect,omitempty"`
+
+	// Errors - Error pages components by status code (see `WithErrorPage`).
+	Errors map[int]string `json:"errors,omitempty" yaml:"errors,omitempty"`
+
+	// Stream - Streams HTML response (see `WithStreaming`).
+	Stream bool `json:"stream,omitempty" yaml:"stream,omitempty"`
+
+	// Produces - Media types of responses allowed on route (see `WithProduces`).
+	Produces []string `json:"produces,omitempty" yaml:"produces,omitempty"`
+
+	// ETag - Key template of `ETag` computed before rendering (see `WithETag`).
+	ETag string `json:"etag,omitempty" yaml:"etag,omitempty"`
+
+	// LastModified - Sets `Last-Modified` from components files (see `WithLastModified`).
+	LastModified bool `json:"last_modified,omitempty" yaml:"last_modified,omitempty"`
+
+	// Cache - Caching policy of responses (see `Cache`).
+	Cache *Cache `json:"cache,omitempty" yaml:"cache,omitempty"`
+
+	// Batch - Renders list of components from request body (see `NewBatch`).
+	Batch bool `json:"batch,omitempty" yaml:"batch,omitempty"`
+
+	// Body - Decodes request body into template context (see `DecodeBody`).
+	Body *Body `json:"body,omitempty" yaml:"body,omitempty"`
+
+	// Static - Serves static files from components storage (see `NewStatic`).
+	Static *Static `json:"static,omitempty" yaml:"static,omitempty"`
 }
 
 // Construct - Constructs http handler.
 func (h *Handler) Construct(opts ...Option) (xhandler.HandlerC, error) {
+	if h.Static != nil {
+		if err := h.checkStatic(); err != nil {
+			return nil, err
+		}
+	}
+
 	// Request initialization middleware
 	opts = append(opts, WithMiddleware(initMiddleware))
 
+	// Decode request body before handler middlewares if set in handler
+	if h.Body != nil {
+		opts = append(opts, WithMiddleware(DecodeBody(h.Body)))
+	}
+
 	// Set component-setting middleware with handler component
 	opts = append(opts, WithComponentSetter(ComponentMiddleware(h.Component)))
 
+	// Set response status, headers and redirect if set in handler
+	if h.Status != 0 {
+		opts = append(opts, WithStatus(h.Status))
+	}
+	for name, value := range h.Headers {
+		t, err := template.FromString(value)
+		if err != nil {
+			return nil, fmt.Errorf("header %q: %v", name, err)
+		}
+		opts = append(opts, WithHeader(name, t))
+	}
+	if h.Redirect != "" {
+		t, err := template.FromString(h.Redirect)
+		if err != nil {
+			return nil, fmt.Errorf("redirect: %v", err)
+		}
+		opts = append(opts, WithRedirect(t))
+	}
+
+	// Set error pages if set in handler
+	for code, component := range h.Errors {
+		opts = append(opts, WithErrorPage(code, component))
+	}
+
+	// Enable streaming if set in handler
+	if h.Stream {
+		opts = append(opts, WithStreaming())
+	}
+
+	// Restrict response types if set in handler
+	if len(h.Produces) != 0 {
+		opts = append(opts, WithProduces(h.Produces...))
+	}
+
+	// Set conditional request validators if set in handler
+	if h.ETag != "" {
+		key, err := template.FromString(h.ETag)
+		if err != nil {
+			return nil, fmt.Errorf("etag: %v", err)
+		}
+		opts = append(opts, WithETag(key))
+	}
+	if h.LastModified {
+		opts = append(opts, WithLastModified())
+	}
+
+	// Set caching policy if set in handler
+	if h.Cache != nil {
+		var key template.Template
+		if h.Cache.Key != "" {
+			var err error
+			key, err = template.FromString(h.Cache.Key)
+			if err != nil {
+				return nil, fmt.Errorf("cache key: %v", err)
+			}
+		}
+		opts = append(opts, WithCache(h.Cache, key))
+	}
+
 	// Check if tracing is enabled
 	tracing := tracingEnabled(opts...)
 
@@ -41,9 +151,43 @@ func (h *Handler) Construct(opts ...Option) (xhandler.HandlerC, error) {
 		opts = append(opts, WithMiddleware(middleware))
 	}
 
+	if h.Batch {
+		return NewBatch(opts...), nil
+	}
+	if h.Static != nil {
+		return NewStatic(h.Static, opts...), nil
+	}
 	return New(opts...), nil
 }
 
+// checkStatic - Returns error if static files handler has settings
+// of rendered responses, which would be ignored.
+func (h *Handler) checkStatic() error {
+	var names []string
+	for name, set := range map[string]bool{
+		"component":     h.Component != nil,
+		"status":        h.Status != 0,
+		"headers":       len(h.Headers) != 0,
+		"redirect":      h.Redirect != "",
+		"stream":        h.Stream,
+		"produces":      len(h.Produces) != 0,
+		"etag":          h.ETag != "",
+		"last_modified": h.LastModified,
+		"cache":         h.Cache != nil,
+		"batch":         h.Batch,
+		"body":          h.Body != nil,
+	} {
+		if set {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+	return fmt.Errorf("static cannot be combined with %s", strings.Join(names, ", "))
+}
+
 var initMiddleware = middlewares.ToHandler(func(ctx context.Context, w http.ResponseWriter, r *http.Request, next xhandler.HandlerC) {
 	ctx = NewRequestContext(ctx, r)
 	ctx = components.WithTemplateKey(ctx, "request", r)
@@ -0,0 +1,37 @@
+package renderer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"tower.pro/renderer/compiler"
+	"tower.pro/renderer/storage"
+)
+
+// testContext - Creates context with compiler of a storage in temporary
+// directory with given components definitions by name.
+func testContext(t *testing.T, defs map[string]string) (context.Context, func()) {
+	dir, err := ioutil.TempDir("", "renderer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, def := range defs {
+		path := filepath.Join(dir, filepath.FromSlash(strings.Replace(name, ".", "/", -1)))
+		os.MkdirAll(path, 0755)
+		err = ioutil.WriteFile(filepath.Join(path, "component.yaml"), []byte(def), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	s, err := storage.New(storage.WithDir(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := compiler.NewContext(context.Background(), compiler.New(s))
+	return ctx, func() { os.RemoveAll(dir) }
+}
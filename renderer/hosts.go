@@ -0,0 +1,148 @@
+package renderer
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/rs/xhandler"
+	"golang.org/x/net/context"
+)
+
+// defaultHost - Virtual host of routes without host and headers.
+var defaultHost = Route{}
+
+// RouteHeaders - Encodes patterns of required request headers
+// to be set in `Route.Headers`, eq. `X-Site=shop`.
+// Patterns can contain `*` wildcards, `*` matches any non-empty value.
+func RouteHeaders(headers map[string]string) string {
+	values := make(url.Values, len(headers))
+	for name, value := range headers {
+		values.Set(http.CanonicalHeaderKey(name), value)
+	}
+	return values.Encode()
+}
+
+// byHost - Returns routes grouped by virtual host.
+// Virtual host is a route with only host and headers set.
+func (routes Routes) byHost() (hosts map[Route]Routes) {
+	hosts = make(map[Route]Routes)
+	for route, h := range routes {
+		host := Route{Host: route.Host, Headers: route.Headers}
+		if hosts[host] == nil {
+			hosts[host] = make(Routes)
+		}
+		hosts[host][route] = h
+	}
+	return
+}
+
+// virtualHost - Handler of routes of a virtual host.
+type virtualHost struct {
+	host    string
+	headers url.Values
+	handler xhandler.HandlerC
+}
+
+// constructHosts - Constructs handler routing requests to handlers
+// of most specific virtual host matching request. Default host,
+// matching any request, is used when no other host matches.
+func constructHosts(hosts map[Route]Routes, options ...Option) (_ xhandler.HandlerC, err error) {
+	if _, ok := hosts[defaultHost]; !ok {
+		hosts[defaultHost] = make(Routes)
+	}
+	var list []*virtualHost
+	for host, routes := range hosts {
+		vh := &virtualHost{host: strings.ToLower(host.Host)}
+		vh.headers, err = url.ParseQuery(host.Headers)
+		if err != nil {
+			return nil, fmt.Errorf("headers %q: %v", host.Headers, err)
+		}
+		if _, err = path.Match(vh.host, ""); err != nil {
+			return nil, fmt.Errorf("host %q: %v", host.Host, err)
+		}
+		vh.handler, err = routes.construct(options...)
+		if err != nil {
+			return
+		}
+		list = append(list, vh)
+	}
+	sort.Sort(virtualHosts(list))
+
+	return xhandler.HandlerFuncC(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		host := requestHost(r)
+		for _, vh := range list {
+			if vh.match(host, r) {
+				vh.handler.ServeHTTPC(ctx, w, r)
+				return
+			}
+		}
+	}), nil
+}
+
+// match - Returns true if request host and headers match virtual host.
+func (vh *virtualHost) match(host string, r *http.Request) bool {
+	if vh.host != "" {
+		if ok, _ := path.Match(vh.host, host); !ok {
+			return false
+		}
+	}
+	for name := range vh.headers {
+		value := r.Header.Get(name)
+		if value == "" {
+			return false
+		}
+		if ok, _ := path.Match(vh.headers.Get(name), value); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// requestHost - Returns lower-cased request host without port.
+func requestHost(r *http.Request) string {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
+
+// virtualHosts - Virtual hosts sorted from most specific: hosts without
+// wildcards first, then longer host patterns and more required headers.
+type virtualHosts []*virtualHost
+
+func (list virtualHosts) Len() int      { return len(list) }
+func (list virtualHosts) Swap(i, j int) { list[i], list[j] = list[j], list[i] }
+func (list virtualHosts) Less(i, j int) bool {
+	a, b := list[i], list[j]
+	if a.specificity() != b.specificity() {
+		return a.specificity() > b.specificity()
+	}
+	if len(a.host) != len(b.host) {
+		return len(a.host) > len(b.host)
+	}
+	if len(a.headers) != len(b.headers) {
+		return len(a.headers) > len(b.headers)
+	}
+	if a.host != b.host {
+		return a.host < b.host
+	}
+	return a.headers.Encode() < b.headers.Encode()
+}
+
+// specificity - Returns 2 for exact host, 1 for host pattern and 0 for any host.
+func (vh *virtualHost) specificity() int {
+	switch {
+	case vh.host == "":
+		return 0
+	case strings.ContainsAny(vh.host, "*?["):
+		return 1
+	default:
+		return 2
+	}
+}
@@ -9,14 +9,31 @@ import (
 
 	"tower.pro/renderer/components"
 	"tower.pro/renderer/middlewares"
+	"tower.pro/renderer/sessions"
 	"tower.pro/renderer/template"
 )
 
 type webOptions struct {
-	tracing    bool
-	alwaysHTML bool
-	reqTimeout time.Duration
-	defaultCtx template.Context
+	tracing      bool
+	debug        bool
+	alwaysHTML   bool
+	preload      bool
+	streaming    bool
+	lastModified bool
+	reqTimeout   time.Duration
+	defaultCtx   template.Context
+	produce      []string
+	etag         template.Template
+	cache        *Cache
+	cacheKey     template.Template
+	cacheStore   *CacheStore
+	compression  bool
+	compressMin  int
+	status       int
+	headers      map[string]template.Template
+	redirect     template.Template
+	errors       map[int]string
+	sessions     *sessions.Store
 
 	middlewares       []middlewares.Handler
 	componentSetter   middlewares.Handler
@@ -49,6 +66,17 @@ func WithTracing(enable ...bool) Option {
 	}
 }
 
+// WithDebug - Enables debug response headers. Uses first parameter if any.
+func WithDebug(enable ...bool) Option {
+	return func(o *webOptions) {
+		if len(enable) == 0 {
+			o.debug = true
+		} else {
+			o.debug = enable[0]
+		}
+	}
+}
+
 // WithComponentSetter - Sets component reader HTTP request middleware.
 func WithComponentSetter(componentSetter middlewares.Handler) Option {
 	return func(o *webOptions) {
@@ -81,6 +109,132 @@ func WithAlwaysHTML(enable ...bool) Option {
 	}
 }
 
+// WithPreload - Enables preloading of URL styles and scripts using `Link` headers
+// and `<link rel="preload">` tags. Uses first parameter if any.
+func WithPreload(enable ...bool) Option {
+	return func(o *webOptions) {
+		if len(enable) == 0 {
+			o.preload = true
+		} else {
+			o.preload = enable[0]
+		}
+	}
+}
+
+// WithStreaming - Enables streaming of HTML responses. Document head with styles
+// is flushed before component body is rendered. Uses first parameter if any.
+func WithStreaming(enable ...bool) Option {
+	return func(o *webOptions) {
+		if len(enable) == 0 {
+			o.streaming = true
+		} else {
+			o.streaming = enable[0]
+		}
+	}
+}
+
+// WithProduces - Restricts media types of responses, eq. `text/html`.
+// Writers with profile are allowed by type or by type with profile,
+// eq. `text/html; profile=fragment`. All types are allowed by default.
+func WithProduces(types ...string) Option {
+	return func(o *webOptions) {
+		o.produce = append(o.produce, types...)
+	}
+}
+
+// WithETag - Sets `ETag` key template. When set, `ETag` is computed
+// from executed key template and compiled components tree hash before rendering,
+// otherwise it's computed from rendered response body.
+func WithETag(key template.Template) Option {
+	return func(o *webOptions) {
+		o.etag = key
+	}
+}
+
+// WithLastModified - Sets `Last-Modified` to modification time of components
+// files in storage. It should be enabled only when response depends only on them.
+// Uses first parameter if any.
+func WithLastModified(enable ...bool) Option {
+	return func(o *webOptions) {
+		if len(enable) == 0 {
+			o.lastModified = true
+		} else {
+			o.lastModified = enable[0]
+		}
+	}
+}
+
+// WithCache - Sets caching policy. Key template is used in place of request
+// host and path in cache keys, it can be nil.
+func WithCache(c *Cache, key template.Template) Option {
+	return func(o *webOptions) {
+		o.cache = c
+		o.cacheKey = key
+	}
+}
+
+// WithCacheStore - Sets store of cached responses. When not set,
+// every handler with caching policy has its own store.
+func WithCacheStore(s *CacheStore) Option {
+	return func(o *webOptions) {
+		o.cacheStore = s
+	}
+}
+
+// WithCompression - Enables gzip compression of responses when accepted
+// by client. Responses smaller than `minSize` bytes are not compressed.
+// Cached responses are stored compressed.
+func WithCompression(minSize int) Option {
+	return func(o *webOptions) {
+		o.compression = true
+		o.compressMin = minSize
+	}
+}
+
+// WithSessions - Enables sessions stored in cookies. Session values
+// are available in template context under `session` key.
+func WithSessions(store *sessions.Store) Option {
+	return func(o *webOptions) {
+		o.sessions = store
+	}
+}
+
+// WithStatus - Sets response status code.
+func WithStatus(code int) Option {
+	return func(o *webOptions) {
+		o.status = code
+	}
+}
+
+// WithHeader - Sets response header value template.
+func WithHeader(name string, value template.Template) Option {
+	return func(o *webOptions) {
+		if o.headers == nil {
+			o.headers = make(map[string]template.Template)
+		}
+		o.headers[name] = value
+	}
+}
+
+// WithRedirect - Sets redirect target template. Component is not rendered
+// when redirecting. Status code is `302 Found` unless redirect status is set.
+func WithRedirect(target template.Template) Option {
+	return func(o *webOptions) {
+		o.redirect = target
+	}
+}
+
+// WithErrorPage - Sets component rendered in place of error responses
+// with status code when HTML is accepted by client.
+func WithErrorPage(code int, component string) Option {
+	return func(o *webOptions) {
+		if o.errors == nil {
+			o.errors = make(map[int]string)
+		}
+		o.errors[code] = component
+	}
+}
+
 // WithDefaultTemplateContext - Sets default template context.
 // Template context is cloned on every request because it's used as a base.
 func WithDefaultTemplateContext(ctx template.Context) Option {
@@ -96,6 +250,27 @@ func WithMiddleware(m middlewares.Handler) Option {
 	}
 }
 
+type optionsCtxKey struct{}
+
+var optionsKey = optionsCtxKey{}
+
+// optionsMiddleware - Sets handler options in request context.
+func optionsMiddleware(o *webOptions) middlewares.Handler {
+	return middlewares.ToHandler(func(ctx context.Context, w http.ResponseWriter, r *http.Request, next xhandler.HandlerC) {
+		ctx = context.WithValue(ctx, optionsKey, o)
+		next.ServeHTTPC(ctx, w, r)
+	})
+}
+
+// optionsFromContext - Retrieves handler options from context.
+// Returns default options if not set.
+func optionsFromContext(ctx context.Context) *webOptions {
+	if o, ok := ctx.Value(optionsKey).(*webOptions); ok {
+		return o
+	}
+	return constructOpts()
+}
+
 func defaultCtxSetter(o *webOptions) middlewares.Handler {
 	return middlewares.ToHandler(func(ctx context.Context, w http.ResponseWriter, r *http.Request, next xhandler.HandlerC) {
 		ctx = components.NewTemplateContext(ctx, o.defaultCtx.Clone())
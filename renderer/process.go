@@ -6,10 +6,13 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/golang/glog"
 	"golang.org/x/net/context"
+	"golang.org/x/net/trace"
+	"gopkg.in/yaml.v2"
 
 	"github.com/rs/xhandler"
 
@@ -31,11 +34,12 @@ func ComponentMiddleware(c *components.Component) middlewares.Handler {
 }
 
 // UnmarshalFromRequest - Unmarshals component using `UnmarshalFromQuery` on `GET`
-// method and `` on `POST` method.
+// method and `UnmarshalFromBody` on `POST` method.
 var UnmarshalFromRequest = NewUnmarshalFromRequest()
 
 // NewUnmarshalFromRequest - Unmarshals component using `UnmarshalFromQuery` on `GET`
-// method and `` on `POST` method.
+// method and `UnmarshalFromBody` on `POST` method.
+// Responds with `405 Method Not Allowed` on other methods.
 func NewUnmarshalFromRequest() middlewares.Handler {
 	get, post := UnmarshalFromQuery("GET"), UnmarshalFromBody("POST")
 	return middlewares.ToHandler(func(ctx context.Context, w http.ResponseWriter, r *http.Request, next xhandler.HandlerC) {
@@ -43,6 +47,9 @@ func NewUnmarshalFromRequest() middlewares.Handler {
 			get(next).ServeHTTPC(ctx, w, r)
 		} else if r.Method == "POST" {
 			post(next).ServeHTTPC(ctx, w, r)
+		} else {
+			w.Header().Set("Allow", "GET, POST")
+			helpers.WriteError(w, r, http.StatusMethodNotAllowed, fmt.Sprintf("method %s not allowed", r.Method))
 		}
 	})
 }
@@ -143,6 +150,9 @@ func CompileInContext(next xhandler.HandlerC) xhandler.HandlerC {
 			helpers.WriteError(w, r, http.StatusExpectationFailed, fmt.Sprintf("compile error: %v", err))
 			return
 		}
+		if tr, ok := trace.FromContext(ctx); ok {
+			tr.LazyPrintf("compiled %s in %v", compiled.Name, compiled.CompileTime)
+		}
 		ctx = components.NewCompiledContext(ctx, compiled)
 		next.ServeHTTPC(ctx, w, r)
 	})
@@ -157,25 +167,132 @@ func RenderInContext(next xhandler.HandlerC) xhandler.HandlerC {
 			helpers.WriteError(w, r, http.StatusBadRequest, "component not compiled")
 			return
 		}
+		o := optionsFromContext(ctx)
+		var opts []components.RenderOption
+		if o.tracing || o.debug {
+			opts = append(opts, components.WithTrace())
+		}
+		if treeRequested(ctx, r) {
+			opts = append(opts, components.WithTree())
+		}
 		t, _ := components.TemplateContext(ctx)
-		res, err := components.Render(c, t)
+		res, err := components.RenderWith(c, t, opts...)
 		if err != nil {
 			helpers.WriteError(w, r, http.StatusExpectationFailed, fmt.Sprintf("render error: %v", err))
 			return
 		}
+		writeRenderTrace(ctx, w, o, res)
 		ctx = components.NewRenderedContext(ctx, res)
 		next.ServeHTTPC(ctx, w, r)
 	})
 }
 
+// StreamRendered - Renders compiled component from context and streams HTML response.
+// Document head with styles is flushed before component body is rendered.
+// Falls back to `RenderInContext` and accepted response writer when response
+// is not HTML, response writer cannot be flushed, components tree is requested
+// or render trace is enabled, so trace and render errors can be written.
+func StreamRendered(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	wr, ok := acceptedWriter(ctx, w, r)
+	if !ok {
+		helpers.WriteError(w, r, http.StatusNotAcceptable, "not acceptable")
+		return
+	}
+	o := optionsFromContext(ctx)
+	_, flusher := w.(http.Flusher)
+	if !flusher || wr.Name != "html" || o.tracing || o.debug || treeRequested(ctx, r) {
+		RenderInContext(wr.Write).ServeHTTPC(ctx, w, r)
+		return
+	}
+	c, ok := components.CompiledFromContext(ctx)
+	if !ok {
+		helpers.WriteError(w, r, http.StatusBadRequest, "component not compiled")
+		return
+	}
+	t, _ := components.TemplateContext(ctx)
+	stream, err := components.NewStream(c, t)
+	if err != nil {
+		helpers.WriteError(w, r, http.StatusExpectationFailed, fmt.Sprintf("render error: %v", err))
+		return
+	}
+	stream.Context = ctx
+	w.Header().Set("Content-Type", "text/html")
+	if o.preload {
+		stream.Preload = true
+		for _, p := range stream.Rendered().Preloads() {
+			w.Header().Add("Link", p.Header())
+		}
+	}
+	if _, err := stream.WriteTo(w); err != nil {
+		abortCache(ctx)
+		glog.Warningf("[api] stream error after response was started: %v", err)
+	}
+}
+
+// writeRenderTrace - Writes render trace to request trace when tracing is enabled.
+// In debug mode writes render trace and errors to response headers,
+// otherwise removes trace from result so it's not written in response.
+func writeRenderTrace(ctx context.Context, w http.ResponseWriter, o *webOptions, res *components.Rendered) {
+	if res.Trace == nil {
+		return
+	}
+	if tr, ok := trace.FromContext(ctx); ok && o.tracing {
+		tr.LazyPrintf("%s", res.Trace)
+	}
+	if !o.debug {
+		res.Trace = nil
+		return
+	}
+	for _, e := range res.Errors {
+		w.Header().Add("X-Render-Error", e)
+	}
+	body, err := json.Marshal(res.Trace)
+	if err != nil {
+		glog.Warningf("[api] trace encode error: %v", err)
+		return
+	}
+	w.Header().Set("X-Render-Trace", string(body))
+}
+
+// TreeRequested - Returns true if request asks for rendered components tree
+// using `tree` query parameter or `Accept: application/json; profile=tree` header.
+func TreeRequested(r *http.Request) bool {
+	return profileRequested(r, "tree", "application/json")
+}
+
+// treeRequested - Returns true if tree writer was negotiated
+// or if tree is requested when response writer was not negotiated.
+func treeRequested(ctx context.Context, r *http.Request) bool {
+	if wr, ok := ctx.Value(writerKey).(*Writer); ok {
+		return wr.Name == "tree"
+	}
+	return TreeRequested(r)
+}
+
+// profileRequested - Returns true if profile is requested in query
+// or accepted with media type.
+func profileRequested(r *http.Request, profile, typ string) bool {
+	if ok, _ := strconv.ParseBool(r.URL.Query().Get(profile)); ok {
+		return true
+	}
+	for _, m := range helpers.ParseAccept(r.Header.Get("Accept")) {
+		if m.Q > 0 && m.Params["profile"] == profile && m.Match(typ) == 2 {
+			return true
+		}
+	}
+	return false
+}
+
 // WriteRendered - Writes rendered component from context to response writer.
-// Depending on `Accept` header, it will write json or plain html body.
+// Response writer is selected using `Accept` header (see `NegotiateInContext`).
+// Responds with `406 Not Acceptable` when no response type is accepted.
 func WriteRendered(ctx context.Context, w http.ResponseWriter, r *http.Request) {
-	if strings.Contains(r.Header.Get("Accept"), "application/json") {
-		WriteRenderedJSON(ctx, w, r)
-	} else {
-		WriteRenderedHTML(ctx, w, r)
+	wr, ok := acceptedWriter(ctx, w, r)
+	if !ok {
+		helpers.WriteError(w, r, http.StatusNotAcceptable, "not acceptable")
+		return
 	}
+	wr.Write(ctx, w, r)
 }
 
 // WriteRenderedJSON - Writes rendered component from context to response writer.
@@ -185,19 +302,72 @@ func WriteRenderedJSON(ctx context.Context, w http.ResponseWriter, r *http.Reque
 		helpers.WriteError(w, r, http.StatusBadRequest, "component not rendered")
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(res); err != nil {
-		glog.Warningf("[api] response encode error: %v", err)
+	body, err := json.Marshal(res)
+	if err != nil {
+		helpers.WriteError(w, r, http.StatusInternalServerError, fmt.Sprintf("response encode error: %v", err))
+		return
+	}
+	writeResponse(w, r, "application/json", append(body, '\n'))
+}
+
+// WriteRenderedYAML - Writes rendered component from context to response writer.
+func WriteRenderedYAML(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	res, ok := components.RenderedFromContext(ctx)
+	if !ok {
+		helpers.WriteError(w, r, http.StatusBadRequest, "component not rendered")
+		return
+	}
+	body, err := yaml.Marshal(res)
+	if err != nil {
+		helpers.WriteError(w, r, http.StatusInternalServerError, fmt.Sprintf("response encode error: %v", err))
+		return
 	}
+	writeResponse(w, r, "application/x-yaml", body)
+}
+
+// WriteRenderedText - Writes rendered component body from context to response writer
+// as plain text.
+func WriteRenderedText(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	res, ok := components.RenderedFromContext(ctx)
+	if !ok {
+		helpers.WriteError(w, r, http.StatusBadRequest, "component not rendered")
+		return
+	}
+	writeResponse(w, r, "text/plain", []byte(res.Body))
 }
 
 // WriteRenderedHTML - Writes rendered component from context to response writer.
+// When preloading is enabled writes `Link` headers for URL styles and scripts.
 func WriteRenderedHTML(ctx context.Context, w http.ResponseWriter, r *http.Request) {
 	res, ok := components.RenderedFromContext(ctx)
 	if !ok {
 		helpers.WriteError(w, r, http.StatusBadRequest, "component not rendered")
 		return
 	}
-	w.Header().Set("Content-Type", "text/html")
-	w.Write([]byte(res.HTML()))
+	if optionsFromContext(ctx).preload {
+		for _, p := range res.Preloads() {
+			w.Header().Add("Link", p.Header())
+		}
+		writeResponse(w, r, "text/html", []byte(res.PreloadHTML()))
+		return
+	}
+	writeResponse(w, r, "text/html", []byte(res.HTML()))
+}
+
+// WriteRenderedFragment - Writes rendered component body from context to response writer
+// without document shell. URL styles and scripts are written in `X-Render-Style`
+// and `X-Render-Script` headers, inline styles and scripts are written with body.
+func WriteRenderedFragment(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	res, ok := components.RenderedFromContext(ctx)
+	if !ok {
+		helpers.WriteError(w, r, http.StatusBadRequest, "component not rendered")
+		return
+	}
+	for _, src := range res.URLStyles() {
+		w.Header().Add("X-Render-Style", src)
+	}
+	for _, src := range res.URLScripts() {
+		w.Header().Add("X-Render-Script", src)
+	}
+	writeResponse(w, r, "text/html", []byte(res.Fragment()))
 }
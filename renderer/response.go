@@ -0,0 +1,138 @@
+package renderer
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/rs/xhandler"
+	"golang.org/x/net/context"
+
+	"tower.pro/renderer/components"
+	"tower.pro/renderer/helpers"
+	"tower.pro/renderer/template"
+)
+
+// ResponseInContext - Sets response status code and headers from options
+// and from `response.status` and `response.headers` template context keys
+// which can be set by middlewares. Redirects to a target from options
+// or from `response.redirect` template context key without rendering.
+// Values from template context take precedence over options.
+func ResponseInContext(next xhandler.HandlerC) xhandler.HandlerC {
+	return xhandler.HandlerFuncC(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		o := optionsFromContext(ctx)
+		t, _ := components.TemplateContext(ctx)
+
+		// Set headers from options templates and from context
+		for name, value := range o.headers {
+			v, err := template.ExecuteToString(value, t)
+			if err != nil {
+				helpers.WriteError(w, r, http.StatusExpectationFailed, fmt.Sprintf("header %q error: %v", name, err))
+				return
+			}
+			w.Header().Set(name, v)
+		}
+		for name, value := range toStringMap(t.Get("response.headers")) {
+			w.Header().Set(name, value)
+		}
+
+		status := o.status
+		if s := toStatus(t.Get("response.status")); s != 0 {
+			status = s
+		}
+
+		// Redirect if target is set
+		target, _ := t.Get("response.redirect").(string)
+		if target == "" && o.redirect != nil {
+			var err error
+			target, err = template.ExecuteToString(o.redirect, t)
+			if err != nil {
+				helpers.WriteError(w, r, http.StatusExpectationFailed, fmt.Sprintf("redirect error: %v", err))
+				return
+			}
+		}
+		if target != "" {
+			if status < 300 || status >= 400 {
+				status = http.StatusFound
+			}
+			http.Redirect(w, r, target, status)
+			return
+		}
+
+		if status == 0 || status == http.StatusOK {
+			next.ServeHTTPC(ctx, w, r)
+			return
+		}
+
+		// Only successful responses can be not modified
+		// Response with status set in route is not an error page
+		passErrors(ctx)
+		sw := &statusWriter{ResponseWriter: w, status: status}
+		next.ServeHTTPC(ctx, helpers.WithFlusher(sw, w, nil), unconditionalRequest(r))
+	})
+}
+
+// statusWriter - Response writer writing status code
+// unless another one is written explicitly.
+type statusWriter struct {
+	http.ResponseWriter
+	status  int
+	written bool
+}
+
+func (sw *statusWriter) WriteHeader(code int) {
+	sw.written = true
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *statusWriter) Write(body []byte) (int, error) {
+	if !sw.written {
+		sw.WriteHeader(sw.status)
+	}
+	return sw.ResponseWriter.Write(body)
+}
+
+// toStatus - Converts template context value to status code.
+// Returns zero if value is not a valid status code.
+func toStatus(v interface{}) (status int) {
+	switch t := v.(type) {
+	case int:
+		status = t
+	case int64:
+		status = int(t)
+	case float64:
+		status = int(t)
+	case string:
+		status, _ = strconv.Atoi(t)
+	}
+	if status < 100 || status > 999 {
+		return 0
+	}
+	return
+}
+
+// toStringMap - Converts template context value to a map of strings.
+func toStringMap(v interface{}) (res map[string]string) {
+	switch t := v.(type) {
+	case map[string]string:
+		return t
+	case http.Header:
+		res = make(map[string]string, len(t))
+		for key := range t {
+			res[key] = t.Get(key)
+		}
+	case template.Context:
+		return toStringMap(map[string]interface{}(t))
+	case map[string]interface{}:
+		res = make(map[string]string, len(t))
+		for key, value := range t {
+			res[key] = fmt.Sprintf("%v", value)
+		}
+	case map[interface{}]interface{}:
+		res = make(map[string]string, len(t))
+		for key, value := range t {
+			res[fmt.Sprintf("%v", key)] = fmt.Sprintf("%v", value)
+		}
+	}
+	return
+}
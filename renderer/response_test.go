@@ -0,0 +1,61 @@
+package renderer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/xhandler"
+	"golang.org/x/net/context"
+
+	"tower.pro/renderer/components"
+	"tower.pro/renderer/template"
+)
+
+func TestResponseInContext(t *testing.T) {
+	header, err := template.FromString("id={{ id }}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	redirect, err := template.FromString("/products/{{ id }}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve := func(tctx template.Context, opts ...Option) *httptest.ResponseRecorder {
+		h := optionsMiddleware(constructOpts(opts...))(ResponseInContext(
+			xhandler.HandlerFuncC(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+				writeResponse(w, r, "text/html", []byte("body"))
+			})))
+		ctx := components.NewTemplateContext(context.Background(), tctx)
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.Header.Set("If-None-Match", strongETag([]byte("body")))
+		w := httptest.NewRecorder()
+		h.ServeHTTPC(ctx, w, r)
+		return w
+	}
+
+	w := serve(template.Context{"id": 1}, WithStatus(http.StatusNotFound), WithHeader("X-Test", header))
+	if w.Code != http.StatusNotFound || w.Body.String() != "body" || w.Header().Get("X-Test") != "id=1" {
+		t.Errorf("Invalid response %d %v: %s", w.Code, w.Header(), w.Body.String())
+	}
+
+	w = serve(template.Context{"id": 1}, WithRedirect(redirect), WithStatus(http.StatusMovedPermanently))
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/products/1" {
+		t.Errorf("Invalid redirect %d %v", w.Code, w.Header())
+	}
+
+	w = serve(template.Context{
+		"response.status": 410,
+		"response": template.Context{
+			"headers": map[string]interface{}{"X-Test": "gone"},
+		},
+	}, WithStatus(http.StatusNotFound))
+	if w.Code != http.StatusGone || w.Header().Get("X-Test") != "gone" {
+		t.Errorf("Invalid response from context %d %v", w.Code, w.Header())
+	}
+
+	w = serve(template.Context{"response.redirect": "/login"})
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/login" {
+		t.Errorf("Invalid redirect from context %d %v", w.Code, w.Header())
+	}
+}
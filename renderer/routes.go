@@ -3,6 +3,7 @@ package renderer
 import (
 	"fmt"
 	"net/http"
+	"sort"
 	"strings"
 
 	"golang.org/x/net/context"
@@ -10,33 +11,84 @@ import (
 
 	"github.com/rs/xhandler"
 	"github.com/rs/xmux"
+
+	"tower.pro/renderer/helpers"
 )
 
 // Route - Web route.
 type Route struct {
 	Path   string `json:"path,omitempty" yaml:"path,omitempty"`
 	Method string `json:"method,omitempty" yaml:"method,omitempty"`
+
+	// Host - Pattern of request host with `*` wildcards, empty matches all hosts.
+	Host string `json:"host,omitempty" yaml:"host,omitempty"`
+
+	// Headers - Patterns of required request headers (see `RouteHeaders`).
+	Headers string `json:"headers,omitempty" yaml:"headers,omitempty"`
 }
 
 // Routes - Routes map.
 type Routes map[Route]*Handler
 
+// NotFoundRoute - Route of a handler of requests not matching any route.
+// Handler responds with `404 Not Found` status unless other status is set.
+// In routes files it's set under `not_found` key.
+var NotFoundRoute = Route{Path: "*"}
+
 // String - Returns string representation of a route.
-func (route Route) String() string {
-	return strings.Join([]string{route.Method, route.Path}, " ")
+func (route Route) String() (res string) {
+	if route.notFound() {
+		res = "not_found"
+		if route.Host != "" {
+			res += " " + route.Host
+		}
+	} else {
+		res = strings.Join([]string{route.Method, route.Host + route.Path}, " ")
+	}
+	if route.Headers != "" {
+		res += " [" + route.Headers + "]"
+	}
+	return
+}
+
+// notFound - Returns true if route is a not found route of any host.
+func (route Route) notFound() bool {
+	return route.Path == NotFoundRoute.Path && route.Method == ""
 }
 
-// Construct - Constructs http router.
+// Construct - Constructs http router. Routes with host or headers
+// are routed to handlers of most specific matching virtual host,
+// other routes are routed to when no virtual host matches.
 func (routes Routes) Construct(options ...Option) (xhandler.HandlerC, error) {
+	hosts := routes.byHost()
+	if len(hosts) == 1 {
+		if defaults, ok := hosts[defaultHost]; ok {
+			return defaults.construct(options...)
+		}
+	}
+	return constructHosts(hosts, options...)
+}
+
+// construct - Constructs http router of routes of one virtual host.
+func (routes Routes) construct(options ...Option) (xhandler.HandlerC, error) {
 	// Create new router
 	mux := xmux.New()
 
 	tracing := tracingEnabled(options...)
 
+	// Respond with allowed methods when method is not allowed
+	mux.HandleMethodNotAllowed = true
+	mux.MethodNotAllowed = routes.methodNotAllowed()
+
 	// Bind all routes handlers
 	for route, handler := range routes {
+		opts := options
+		if route.notFound() {
+			opts = append([]Option{WithStatus(http.StatusNotFound)}, options...)
+		}
+
 		// Construct handler
-		h, err := handler.Construct(options...)
+		h, err := handler.Construct(opts...)
 		if err != nil {
 			return nil, fmt.Errorf("%q: %v", route, err)
 		}
@@ -46,13 +98,82 @@ func (routes Routes) Construct(options ...Option) (xhandler.HandlerC, error) {
 		}
 
 		// Bind route handler
+		if route.notFound() {
+			mux.NotFound = h
+			continue
+		}
 		mux.HandleC(route.Method, route.Path, h)
+
+		// Bind HEAD handler for GET route unless it's set
+		if route.Method == "GET" && routes[Route{Method: "HEAD", Path: route.Path, Host: route.Host, Headers: route.Headers}] == nil {
+			mux.HandleC("HEAD", route.Path, headHandler(h))
+		}
 	}
 
 	// Return handler
 	return mux, nil
 }
 
+// methodNotAllowed - Responds with `405 Method Not Allowed`
+// and methods of routes matching request path in `Allow` header.
+func (routes Routes) methodNotAllowed() xhandler.HandlerC {
+	return xhandler.HandlerFuncC(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", strings.Join(routes.allowed(r.URL.Path), ", "))
+		helpers.WriteError(w, r, http.StatusMethodNotAllowed, fmt.Sprintf("method %s not allowed", r.Method))
+	})
+}
+
+// allowed - Returns sorted methods of routes matching path.
+func (routes Routes) allowed(path string) (methods []string) {
+	for route := range routes {
+		if route.notFound() || !matchPath(route.Path, path) {
+			continue
+		}
+		if !helpers.Contain(methods, route.Method) {
+			methods = append(methods, route.Method)
+		}
+		if route.Method == "GET" && !helpers.Contain(methods, "HEAD") {
+			methods = append(methods, "HEAD")
+		}
+	}
+	sort.Strings(methods)
+	return
+}
+
+// matchPath - Returns true if path matches route path pattern
+// with `:name` parameters and `*name` catch-all parameter.
+func matchPath(pattern, path string) bool {
+	parts, segments := strings.Split(pattern, "/"), strings.Split(path, "/")
+	for index, part := range parts {
+		if strings.HasPrefix(part, "*") {
+			return true
+		}
+		if index >= len(segments) {
+			return false
+		}
+		if !strings.HasPrefix(part, ":") && part != segments[index] {
+			return false
+		}
+	}
+	return len(parts) == len(segments)
+}
+
+// headHandler - Handles HEAD request like GET request without writing body.
+func headHandler(next xhandler.HandlerC) xhandler.HandlerC {
+	return xhandler.HandlerFuncC(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTPC(ctx, helpers.WithFlusher(&headWriter{ResponseWriter: w}, w, nil), r)
+	})
+}
+
+// headWriter - Response writer discarding response body.
+type headWriter struct {
+	http.ResponseWriter
+}
+
+func (hw *headWriter) Write(body []byte) (int, error) {
+	return len(body), nil
+}
+
 // ToStringMap - To map with string routes.
 func (routes Routes) ToStringMap() (res map[string]*Handler) {
 	res = make(map[string]*Handler)
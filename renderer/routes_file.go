@@ -3,6 +3,9 @@ package renderer
 import (
 	"fmt"
 	"io/ioutil"
+	"path"
+	"path/filepath"
+	"sort"
 	"strings"
 
 	"gopkg.in/yaml.v2"
@@ -12,44 +15,451 @@ import (
 	"tower.pro/renderer/middlewares"
 )
 
-// RoutesFromFile - Reads routes from yaml file.
+// RoutesFromFile - Reads routes from yaml file and files included in it.
+// Included paths are relative to the file and can be glob patterns.
+// Files can be included only once, so error pages defaults of included
+// routes don't depend on order of includes.
 func RoutesFromFile(filename string) (Routes, error) {
+	r := &routesReader{reading: make(map[string]bool), read: make(map[string]bool)}
+	return r.readRoutes(filename)
+}
+
+// routesReader - Tracks routes files being read to detect include cycles
+// and files already read to detect files included more than once.
+type routesReader struct {
+	reading map[string]bool
+	read    map[string]bool
+}
+
+// readRoutes - Reads routes from yaml file and files included in it.
+func (r *routesReader) readRoutes(filename string) (routes Routes, err error) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return
+	}
+	if r.reading[abs] {
+		return nil, fmt.Errorf("routes file %q includes itself", filename)
+	}
+	if r.read[abs] {
+		return nil, fmt.Errorf("routes file %q is included more than once", filename)
+	}
+	r.reading[abs] = true
+	defer func() {
+		delete(r.reading, abs)
+		r.read[abs] = true
+	}()
+
 	data, err := ioutil.ReadFile(filename)
 	if err != nil {
-		return nil, err
+		return
+	}
+	config, err := parseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	routes, err = config.routes()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	for _, pattern := range config.Include {
+		var names []string
+		names, err = includedFiles(filepath.Dir(filename), pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: include %q: %v", filename, pattern, err)
+		}
+		for _, name := range names {
+			var included Routes
+			included, err = r.readRoutes(name)
+			if err != nil {
+				return
+			}
+			for route, h := range included {
+				if _, exists := routes[route]; exists {
+					return nil, fmt.Errorf("route %q in %q is not unique", route, name)
+				}
+				routes[route] = h
+			}
+		}
+	}
+
+	// Error pages of including file are defaults of included routes
+	config.apply(routes)
+	return
+}
+
+// includedFiles - Returns sorted names of files matching include pattern.
+// Pattern is relative to `dir` unless absolute.
+func includedFiles(dir, pattern string) (names []string, err error) {
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(dir, pattern)
+	}
+	names, err = filepath.Glob(pattern)
+	if err != nil {
+		return
+	}
+	// Report missing file if pattern is not a glob
+	if len(names) == 0 && !strings.ContainsAny(pattern, "*?[") {
+		return []string{pattern}, nil
 	}
-	m := make(routesFile)
-	err = yaml.Unmarshal([]byte(data), &m)
+	sort.Strings(names)
+	return
+}
+
+// parseRoutes - Parses routes from yaml routes file content.
+func parseRoutes(data []byte) (routes Routes, err error) {
+	config, err := parseConfig(data)
 	if err != nil {
-		return nil, err
+		return
+	}
+	routes, err = config.routes()
+	if err != nil {
+		return
 	}
-	return m.toRoutes()
+	config.apply(routes)
+	return
+}
+
+// parseConfig - Parses yaml routes file content.
+func parseConfig(data []byte) (config *routesConfig, err error) {
+	config = new(routesConfig)
+	err = yaml.Unmarshal(data, config)
+	return
+}
+
+// OverlayFilename - Returns name of environment overlay of routes file,
+// eq. `routes.prod.yaml` for `routes.yaml` in `prod` environment.
+func OverlayFilename(filename, env string) string {
+	ext := filepath.Ext(filename)
+	return fmt.Sprintf("%s.%s%s", strings.TrimSuffix(filename, ext), env, ext)
+}
+
+// OverlayFromFile - Overrides settings of routes with settings from yaml file.
+// Settings of existing routes are merged with ones set in overlay, maps are merged
+// and lists are replaced. Routes which don't exist are added.
+// Routes in groups are overridden by full path, routes of virtual hosts
+// are set in `hosts` by host and headers. Includes and groups are not allowed.
+func (routes Routes) OverlayFromFile(filename string) (err error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return
+	}
+	if err = routes.overlay(data); err != nil {
+		return fmt.Errorf("%s: %v", filename, err)
+	}
+	return
+}
+
+// overlay - Overrides settings of routes with settings from yaml content.
+func (routes Routes) overlay(data []byte) (err error) {
+	config := new(struct {
+		Errors  map[int]string           `yaml:"errors,omitempty"`
+		Include []string                 `yaml:"include,omitempty"`
+		Groups  []interface{}            `yaml:"groups,omitempty"`
+		Hosts   []*overlayHost           `yaml:"hosts,omitempty"`
+		Routes  map[string]yaml.MapSlice `yaml:",inline"`
+	})
+	if err = yaml.Unmarshal(data, config); err != nil {
+		return
+	}
+	if len(config.Include) != 0 || len(config.Groups) != 0 {
+		return fmt.Errorf("include and groups cannot be set in overlay")
+	}
+	if err = routes.overlayRoutes(config.Routes, defaultHost); err != nil {
+		return
+	}
+	for _, host := range config.Hosts {
+		if host.Host == "" && len(host.Headers) == 0 {
+			return fmt.Errorf("host or headers have to be set in overlay hosts")
+		}
+		vh := Route{Host: host.Host, Headers: RouteHeaders(host.Headers)}
+		if err = routes.overlayRoutes(host.Routes, vh); err != nil {
+			return
+		}
+	}
+	(&routesConfig{Errors: config.Errors}).apply(routes)
+	return
+}
+
+// overlayHost - Overlay of routes of a virtual host.
+type overlayHost struct {
+	Host    string                   `yaml:"host,omitempty"`
+	Headers map[string]string        `yaml:"headers,omitempty"`
+	Routes  map[string]yaml.MapSlice `yaml:"routes,omitempty"`
+}
+
+// overlayRoutes - Overrides settings of routes of a virtual host.
+func (routes Routes) overlayRoutes(overlay map[string]yaml.MapSlice, host Route) (err error) {
+	for key, settings := range overlay {
+		var keyRoutes []Route
+		if keyRoutes, err = parseRouteKey(key); err != nil {
+			return
+		}
+		var body []byte
+		if body, err = yaml.Marshal(settings); err != nil {
+			return
+		}
+		// Routes added from one key share handler
+		added := new(Handler)
+		for _, route := range keyRoutes {
+			route.Host, route.Headers = host.Host, host.Headers
+			h, ok := routes[route]
+			if ok {
+				// Handlers can be shared by routes, overlay a copy
+				if h, err = cloneHandler(h); err != nil {
+					return fmt.Errorf("route %q: %v", key, err)
+				}
+			} else {
+				h = added
+			}
+			// Decode overlay settings into handler
+			if err = yaml.Unmarshal(body, h); err != nil {
+				return fmt.Errorf("route %q: %v", key, err)
+			}
+			if err = cleanComponent(h.Component); err != nil {
+				return fmt.Errorf("route %q: %v", key, err)
+			}
+			if err = cleanMiddlewares(h.Middlewares); err != nil {
+				return fmt.Errorf("route %q: %v", key, err)
+			}
+			routes[route] = h
+		}
+	}
+	return
+}
+
+// cloneHandler - Returns deep copy of a handler.
+func cloneHandler(h *Handler) (clone *Handler, err error) {
+	body, err := yaml.Marshal(h)
+	if err != nil {
+		return
+	}
+	clone = new(Handler)
+	err = yaml.Unmarshal(body, clone)
+	return
 }
 
 type routesFile map[string]*Handler
 
+// routesConfig - Reserved keys of routes file which are not routes.
+type routesConfig struct {
+	// Errors - Error pages components by status code used in all routes.
+	Errors map[int]string `yaml:"errors,omitempty"`
+
+	// Groups - Groups of routes.
+	Groups []*routesGroup `yaml:"groups,omitempty"`
+
+	// Include - Routes files included, relative paths or glob patterns.
+	Include []string `yaml:"include,omitempty"`
+
+	// Hosts - Routes of virtual hosts.
+	Hosts []*routesHost `yaml:"hosts,omitempty"`
+
+	// Routes - Routes by method and path.
+	Routes routesFile `yaml:",inline"`
+}
+
+// reservedKeys - Keys of routes file which are not routes.
+var reservedKeys = []string{"errors", "groups", "include", "hosts"}
+
+// routes - Returns routes and routes in groups.
+func (config *routesConfig) routes() (routes Routes, err error) {
+	routes, err = config.Routes.toRoutes()
+	if err != nil {
+		return
+	}
+	for _, group := range config.Groups {
+		if err = group.expand("", nil, nil, routes); err != nil {
+			return nil, err
+		}
+	}
+	for _, host := range config.Hosts {
+		if err = host.expand(routes); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+// apply - Sets routes file configuration defaults in routes.
+func (config *routesConfig) apply(routes Routes) {
+	for _, h := range routes {
+		for code, component := range config.Errors {
+			if _, ok := h.Errors[code]; ok {
+				continue
+			}
+			if h.Errors == nil {
+				h.Errors = make(map[int]string)
+			}
+			h.Errors[code] = component
+		}
+	}
+}
+
+// routesHost - Routes of virtual host matching request host and headers.
+type routesHost struct {
+	// Host - Pattern of request host with `*` wildcards.
+	Host string `yaml:"host,omitempty"`
+
+	// Headers - Patterns of required request headers by name.
+	Headers map[string]string `yaml:"headers,omitempty"`
+
+	// Errors - Error pages components by status code used in host routes.
+	Errors map[int]string `yaml:"errors,omitempty"`
+
+	// Routes - Routes of virtual host.
+	Routes routesFile `yaml:"routes,omitempty"`
+
+	// Groups - Groups of routes of virtual host.
+	Groups []*routesGroup `yaml:"groups,omitempty"`
+}
+
+// expand - Expands virtual host routes into `routes`.
+func (host *routesHost) expand(routes Routes) (err error) {
+	if host.Host == "" && len(host.Headers) == 0 {
+		return fmt.Errorf("host or headers have to be set in host")
+	}
+	hostRoutes, err := (&routesConfig{Routes: host.Routes, Groups: host.Groups}).routes()
+	if err != nil {
+		return fmt.Errorf("host %q: %v", host.Host, err)
+	}
+	(&routesConfig{Errors: host.Errors}).apply(hostRoutes)
+
+	headers := RouteHeaders(host.Headers)
+	for route, h := range hostRoutes {
+		route.Host = host.Host
+		route.Headers = headers
+		if _, exists := routes[route]; exists {
+			return fmt.Errorf("route %q is not unique", route)
+		}
+		routes[route] = h
+	}
+	return
+}
+
+// routesGroup - Group of routes with shared path prefix, middlewares
+// and component defaults. Groups can be nested.
+type routesGroup struct {
+	// Prefix - Path prefix of routes in group.
+	Prefix string `yaml:"prefix,omitempty"`
+
+	// Middlewares - Middlewares running before routes middlewares.
+	Middlewares []*middlewares.Middleware `yaml:"middlewares,omitempty"`
+
+	// Component - Defaults of routes components: context, with and extends.
+	Component *components.Component `yaml:"component,omitempty"`
+
+	// Routes - Routes in group.
+	Routes routesFile `yaml:"routes,omitempty"`
+
+	// Groups - Nested groups.
+	Groups []*routesGroup `yaml:"groups,omitempty"`
+}
+
+// expand - Expands group routes with parent group prefix, middlewares
+// and component defaults into `routes`.
+func (group *routesGroup) expand(prefix string, mws []*middlewares.Middleware, c *components.Component, routes Routes) (err error) {
+	if group.Prefix != "" && !strings.HasPrefix(group.Prefix, "/") {
+		return fmt.Errorf("group %q: prefix should start with /", group.Prefix)
+	}
+	prefix = joinPath(prefix, group.Prefix)
+	if err = cleanMiddlewares(group.Middlewares); err != nil {
+		return fmt.Errorf("group %q: %v", prefix, err)
+	}
+	mws = append(mws[:len(mws):len(mws)], group.Middlewares...)
+	if err = cleanComponent(group.Component); err != nil {
+		return fmt.Errorf("group %q: %v", prefix, err)
+	}
+	c = withComponentDefaults(group.Component, c)
+
+	if _, ok := group.Routes["not_found"]; ok {
+		return fmt.Errorf("group %q: not_found cannot be set in a group", prefix)
+	}
+	groupRoutes, err := group.Routes.toRoutes()
+	if err != nil {
+		return fmt.Errorf("group %q: %v", prefix, err)
+	}
+	// Handler can be shared by routes with multiple methods
+	expanded := make(map[*Handler]bool)
+	for route, h := range groupRoutes {
+		route.Path = joinPath(prefix, route.Path)
+		if _, exists := routes[route]; exists {
+			return fmt.Errorf("route %q is not unique", route)
+		}
+		if !expanded[h] {
+			expanded[h] = true
+			h.Middlewares = append(mws[:len(mws):len(mws)], h.Middlewares...)
+			// Defaults are set only on components of routes rendering them
+			if h.Component != nil && h.Static == nil && !h.Batch {
+				h.Component = withComponentDefaults(h.Component, c)
+			}
+		}
+		routes[route] = h
+	}
+
+	for _, child := range group.Groups {
+		if err = child.expand(prefix, mws, c, routes); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// joinPath - Joins path prefix and path keeping trailing slash of the path,
+// eq. `/blog` and `/` is `/blog/`.
+func joinPath(prefix, p string) string {
+	res := path.Join(prefix, p)
+	if strings.HasSuffix(p, "/") && !strings.HasSuffix(res, "/") {
+		res += "/"
+	}
+	return res
+}
+
+// withComponentDefaults - Sets context, with and extends of a component
+// from defaults if not set. Returns copy of defaults if component is nil.
+func withComponentDefaults(c, defaults *components.Component) *components.Component {
+	if defaults == nil {
+		return c
+	}
+	if c == nil {
+		c = new(components.Component)
+		*c = *defaults
+		c.Context = defaults.Context.Clone()
+		c.With = defaults.With.Clone()
+		return c
+	}
+	c.Context = c.Context.WithDefaults(defaults.Context)
+	c.With = c.With.WithDefaults(defaults.With)
+	if c.Extends == "" {
+		c.Extends = defaults.Extends
+	}
+	return c
+}
+
 func (file routesFile) toRoutes() (routes Routes, err error) {
 	routes = make(Routes)
 	for r, h := range file {
-		var route Route
-		route, err = parseRoute(r)
+		if helpers.Contain(reservedKeys, r) {
+			continue
+		}
+		keyRoutes, err := parseRouteKey(r)
 		if err != nil {
-			return
+			return nil, err
 		}
 
 		if err = cleanComponent(h.Component); err != nil {
-			return
+			return nil, err
 		}
-		for _, m := range h.Middlewares {
-			if !middlewares.Exists(m.Name) {
-				err = fmt.Errorf("middleware %q doesn't exist", m.Name)
-				return
-			}
-			if err := cleanMiddleware(m); err != nil {
-				return nil, fmt.Errorf("route %q: %v", r, err)
+		if err := cleanMiddlewares(h.Middlewares); err != nil {
+			return nil, fmt.Errorf("route %q: %v", r, err)
+		}
+		// Routes of all methods in key share handler
+		for _, route := range keyRoutes {
+			if _, exists := routes[route]; exists {
+				return nil, fmt.Errorf("route %q is not unique", route)
 			}
+			routes[route] = h
 		}
-		routes[route] = h
 	}
 	return
 }
@@ -66,6 +476,18 @@ func cleanComponent(c *components.Component) (err error) {
 	return
 }
 
+func cleanMiddlewares(list []*middlewares.Middleware) (err error) {
+	for _, m := range list {
+		if !middlewares.Exists(m.Name) {
+			return fmt.Errorf("middleware %q doesn't exist", m.Name)
+		}
+		if err = cleanMiddleware(m); err != nil {
+			return
+		}
+	}
+	return
+}
+
 func cleanMiddleware(m *middlewares.Middleware) (err error) {
 	m.Options, err = helpers.CleanDeepMap(m.Options)
 	if err != nil {
@@ -79,13 +501,40 @@ func cleanMiddleware(m *middlewares.Middleware) (err error) {
 	return
 }
 
-func parseRoute(str string) (r Route, err error) {
-	s := strings.Split(str, " ")
-	if len(s) != 2 {
-		err = fmt.Errorf("invalid route %q", str)
-		return
+// AnyMethods - Methods of routes with `ANY` method in routes files.
+var AnyMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+
+// parseRouteKey - Parses routes file key to routes. Key consists of
+// comma-separated methods or `ANY` and a path, eq. `GET,POST /search`,
+// `not_found` key is parsed to `NotFoundRoute`.
+func parseRouteKey(str string) (routes []Route, err error) {
+	if str == "not_found" {
+		return []Route{NotFoundRoute}, nil
+	}
+	index := strings.LastIndex(str, " ")
+	if index <= 0 || index == len(str)-1 {
+		return nil, fmt.Errorf("invalid route %q", str)
+	}
+	var methods []string
+	for _, method := range strings.Split(str[:index], ",") {
+		method = strings.ToUpper(strings.TrimSpace(method))
+		switch {
+		case method == "" || strings.ContainsAny(method, " \t"):
+			return nil, fmt.Errorf("invalid route %q", str)
+		case method == "ANY":
+			methods = append(methods, AnyMethods...)
+		default:
+			methods = append(methods, method)
+		}
+	}
+	for _, method := range methods {
+		route := Route{Method: method, Path: str[index+1:]}
+		for _, r := range routes {
+			if r == route {
+				return nil, fmt.Errorf("invalid route %q: duplicated method %s", str, method)
+			}
+		}
+		routes = append(routes, route)
 	}
-	r.Method = s[0]
-	r.Path = s[1]
 	return
 }
@@ -3,8 +3,13 @@ package renderer
 import (
 	"bytes"
 	"encoding/json"
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/rs/xhandler"
@@ -117,3 +122,448 @@ func init() {
 		}), nil
 	})
 }
+
+func TestRoutesFromFileErrors(t *testing.T) {
+	f, err := ioutil.TempFile("", "routes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`
+errors:
+  404: site.not_found
+  500: site.error
+GET /:
+  component:
+    name: site.home
+GET /admin:
+  errors:
+    404: admin.not_found
+  component:
+    name: admin.home
+`)
+	f.Close()
+
+	routes, err := RoutesFromFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 2 {
+		t.Fatalf("Invalid routes: %#v", routes)
+	}
+	expected := map[string]map[int]string{
+		"/":      {404: "site.not_found", 500: "site.error"},
+		"/admin": {404: "admin.not_found", 500: "site.error"},
+	}
+	for route, h := range routes {
+		if !reflect.DeepEqual(h.Errors, expected[route.Path]) {
+			t.Errorf("Invalid %q errors: %v", route, h.Errors)
+		}
+	}
+}
+
+func TestRoutesConstructNotFound(t *testing.T) {
+	ctx, cleanup := testContext(t, map[string]string{
+		"site.product":   `main: 'template://product {{ params.Get("id") }}'`,
+		"site.not_found": "main: template://not found {{ request.URL.Path }}",
+	})
+	defer cleanup()
+
+	m := make(routesFile)
+	err := yaml.Unmarshal([]byte(`
+GET /products/:id:
+  component:
+    name: site.product
+PUT /products/:id:
+  component:
+    name: site.product
+not_found:
+  component:
+    name: site.not_found
+`), &m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes, err := m.toRoutes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := routes.Construct()
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, path, nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTPC(ctx, w, r)
+		return w
+	}
+
+	if w := serve("GET", "/products/1"); w.Code != http.StatusOK || w.Body.String() != "product 1" {
+		t.Errorf("Invalid response %d: %s", w.Code, w.Body.String())
+	}
+	if w := serve("GET", "/missing"); w.Code != http.StatusNotFound || w.Body.String() != "not found /missing" {
+		t.Errorf("Invalid not found response %d: %s", w.Code, w.Body.String())
+	}
+	if w := serve("DELETE", "/products/1"); w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET, HEAD, PUT" {
+		t.Errorf("Invalid method not allowed response %d: %v", w.Code, w.Header())
+	}
+}
+
+func TestRoutesGroups(t *testing.T) {
+	routes, err := parseRoutes([]byte(`
+GET /:
+  component:
+    name: site.home
+groups:
+- prefix: /admin
+  middlewares:
+  - name: my_test_middleware
+    options:
+      opts1: admin
+  component:
+    extends: admin.layout
+    context:
+      section: admin
+  routes:
+    GET /users:
+      component:
+        name: admin.users
+        context:
+          title: Users
+      middlewares:
+      - name: my_test_middleware
+        options:
+          opts1: users
+    GET /logout:
+      redirect: /
+    POST /batch:
+      batch: true
+  groups:
+  - prefix: /settings
+    component:
+      extends: admin.settings
+    routes:
+      GET /:
+        component:
+          name: admin.settings.index
+      GET /profile/:
+        component:
+          name: admin.settings.profile
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 6 {
+		t.Fatalf("Invalid routes: %v", routes.ToStringMap())
+	}
+	for _, path := range []string{"/admin/logout", "/admin/batch"} {
+		for route, h := range routes {
+			if route.Path == path && h.Component != nil {
+				t.Errorf("Route %s without component got defaults: %#v", path, h.Component)
+			}
+		}
+	}
+
+	users := routes[Route{Method: "GET", Path: "/admin/users"}]
+	if users == nil {
+		t.Fatalf("Group route not found: %v", routes.ToStringMap())
+	}
+	if len(users.Middlewares) != 2 || users.Middlewares[0].Options["opts1"] != "admin" || users.Middlewares[1].Options["opts1"] != "users" {
+		t.Errorf("Invalid middlewares order: %#v", users.Middlewares)
+	}
+	expected := template.Context{"section": "admin", "title": "Users"}
+	if users.Component.Extends != "admin.layout" || !reflect.DeepEqual(users.Component.Context, expected) {
+		t.Errorf("Invalid component: %#v", users.Component)
+	}
+
+	settings := routes[Route{Method: "GET", Path: "/admin/settings/"}]
+	if settings == nil || routes[Route{Method: "GET", Path: "/admin/settings/profile/"}] == nil {
+		t.Fatalf("Nested group route not found: %v", routes.ToStringMap())
+	}
+	if len(settings.Middlewares) != 1 || settings.Component.Extends != "admin.settings" || settings.Component.Context["section"] != "admin" {
+		t.Errorf("Invalid nested group route: %#v %#v", settings.Middlewares, settings.Component)
+	}
+
+	if _, err := parseRoutes([]byte("groups:\n- prefix: admin\n  routes:\n    GET /:\n      status: 200\n")); err == nil {
+		t.Error("Expected invalid prefix error")
+	}
+}
+
+func TestRoutesFromFileIncludes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "routes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"routes.yaml": `
+include:
+- sections/*.yaml
+errors:
+  404: site.not_found
+GET /:
+  component:
+    name: site.home
+`,
+		"sections/admin.yaml": `
+GET /admin:
+  component:
+    name: admin.dashboard
+    context:
+      title: Admin
+      section: admin
+`,
+		"sections/blog.yaml": `
+GET /blog:
+  stream: true
+  component:
+    name: blog.list
+`,
+		"routes.prod.yaml": `
+GET /admin:
+  status: 403
+  component:
+    context:
+      title: Forbidden
+GET /health:
+  component:
+    name: site.health
+`,
+	}
+	for name, body := range files {
+		filename := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	filename := filepath.Join(dir, "routes.yaml")
+	routes, err := RoutesFromFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 3 {
+		t.Fatalf("Invalid routes: %v", routes.ToStringMap())
+	}
+	admin := routes[Route{Method: "GET", Path: "/admin"}]
+	if admin == nil || admin.Errors[404] != "site.not_found" {
+		t.Fatalf("Invalid included route: %#v", admin)
+	}
+
+	overlay := OverlayFilename(filename, "prod")
+	if overlay != filepath.Join(dir, "routes.prod.yaml") {
+		t.Errorf("Invalid overlay filename %q", overlay)
+	}
+	if err := routes.OverlayFromFile(overlay); err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 4 || routes[Route{Method: "GET", Path: "/health"}] == nil {
+		t.Fatalf("Invalid routes: %v", routes.ToStringMap())
+	}
+	admin = routes[Route{Method: "GET", Path: "/admin"}]
+	expected := template.Context{"title": "Forbidden", "section": "admin"}
+	if admin.Status != 403 || admin.Component.Name != "admin.dashboard" || !reflect.DeepEqual(admin.Component.Context, expected) {
+		t.Errorf("Invalid overlay route: %#v %#v", admin, admin.Component)
+	}
+
+	// Include cycle
+	ioutil.WriteFile(filepath.Join(dir, "sections/blog.yaml"), []byte("include:\n- ../routes.yaml\n"), 0644)
+	if _, err := RoutesFromFile(filename); err == nil {
+		t.Error("Expected include cycle error")
+	}
+
+	// Files included more than once (eq. diamond include) are an error,
+	// so error pages of included routes don't depend on order of includes
+	ioutil.WriteFile(filepath.Join(dir, "common.yaml"), []byte("GET /common:\n  component:\n    name: site.common\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "sections/blog.yaml"), []byte("errors:\n  404: blog.not_found\ninclude:\n- ../common.yaml\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "sections/admin.yaml"), []byte("errors:\n  404: admin.not_found\ninclude:\n- ../common.yaml\n"), 0644)
+	if _, err := RoutesFromFile(filename); err == nil || !strings.Contains(err.Error(), "included more than once") {
+		t.Errorf("Expected error of file included more than once, got: %v", err)
+	}
+
+	// File included once is valid
+	ioutil.WriteFile(filepath.Join(dir, "sections/admin.yaml"), []byte("errors:\n  404: admin.not_found\n"), 0644)
+	routes, err = RoutesFromFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if common := routes[Route{Method: "GET", Path: "/common"}]; common == nil || common.Errors[404] != "blog.not_found" {
+		t.Errorf("Invalid routes: %v", routes.ToStringMap())
+	}
+}
+
+func TestRoutesOverlaySharedHandler(t *testing.T) {
+	routes, err := parseRoutes([]byte(`
+GET,POST /form:
+  component:
+    name: site.form
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := routes.overlay([]byte("POST /form:\n  status: 201\n")); err != nil {
+		t.Fatal(err)
+	}
+	get := routes[Route{Method: "GET", Path: "/form"}]
+	post := routes[Route{Method: "POST", Path: "/form"}]
+	if get.Status != 0 || post.Status != 201 || post.Component.Name != "site.form" {
+		t.Errorf("Invalid overlay routes: %#v %#v", get, post)
+	}
+}
+
+func TestRoutesMethods(t *testing.T) {
+	ctx, cleanup := testContext(t, map[string]string{
+		"site.search": "main: template://search {{ request.Method }}",
+	})
+	defer cleanup()
+
+	routes, err := parseRoutes([]byte(`
+GET,POST /search:
+  component:
+    name: site.search
+ANY /any:
+  component:
+    name: site.search
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 2+len(AnyMethods) {
+		t.Fatalf("Invalid routes: %v", routes.ToStringMap())
+	}
+	if routes[Route{Method: "GET", Path: "/search"}] != routes[Route{Method: "POST", Path: "/search"}] {
+		t.Error("Expected shared handler")
+	}
+
+	h, err := routes.Construct()
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, path, nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTPC(ctx, w, r)
+		return w
+	}
+	if w := serve("POST", "/search"); w.Code != http.StatusOK || w.Body.String() != "search POST" {
+		t.Errorf("Invalid response %d: %s", w.Code, w.Body.String())
+	}
+	if w := serve("HEAD", "/search"); w.Code != http.StatusOK || w.Body.Len() != 0 || w.Header().Get("ETag") == "" {
+		t.Errorf("Invalid HEAD response %d %v: %s", w.Code, w.Header(), w.Body.String())
+	}
+	if w := serve("DELETE", "/any"); w.Code != http.StatusOK || w.Body.String() != "search DELETE" {
+		t.Errorf("Invalid response %d: %s", w.Code, w.Body.String())
+	}
+	if w := serve("PUT", "/search"); w.Header().Get("Allow") != "GET, HEAD, POST" {
+		t.Errorf("Invalid method not allowed response %d: %v", w.Code, w.Header())
+	}
+
+	for _, key := range []string{"GET /a /b", "GET,,POST /a", "GET,GET /a", "/a", "GET "} {
+		if _, err := parseRouteKey(key); err == nil {
+			t.Errorf("Expected error parsing %q", key)
+		}
+	}
+}
+
+func TestRoutesHosts(t *testing.T) {
+	ctx, cleanup := testContext(t, map[string]string{
+		"site.home":   "main: template://home",
+		"shop.home":   "main: template://shop {{ request.Host }}",
+		"tenant.home": "main: template://tenant",
+		"shop.error":  "main: template://shop not found",
+	})
+	defer cleanup()
+
+	routes, err := parseRoutes([]byte(`
+GET /:
+  component:
+    name: site.home
+hosts:
+- host: "*.shop.example.com"
+  routes:
+    GET /:
+      component:
+        name: shop.home
+    not_found:
+      component:
+        name: shop.error
+- host: "*.shop.example.com"
+  headers:
+    x-tenant: acme
+  routes:
+    GET /:
+      component:
+        name: tenant.home
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 4 {
+		t.Fatalf("Invalid routes: %v", routes.ToStringMap())
+	}
+	if r := (Route{Method: "GET", Path: "/", Host: "*.shop.example.com", Headers: "X-Tenant=acme"}); routes[r] == nil {
+		t.Fatalf("Route %q not found in %v", r, routes.ToStringMap())
+	}
+
+	h, err := routes.Construct()
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve := func(host, path string, headers map[string]string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("GET", path, nil)
+		r.Host = host
+		for name, value := range headers {
+			r.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTPC(ctx, w, r)
+		return w
+	}
+	if w := serve("example.com", "/", nil); w.Body.String() != "home" {
+		t.Errorf("Invalid default host response %d: %s", w.Code, w.Body.String())
+	}
+	if w := serve("A.Shop.Example.com:8080", "/", nil); w.Body.String() != "shop A.Shop.Example.com:8080" {
+		t.Errorf("Invalid host response %d: %s", w.Code, w.Body.String())
+	}
+	if w := serve("a.shop.example.com", "/", map[string]string{"X-Tenant": "acme"}); w.Body.String() != "tenant" {
+		t.Errorf("Invalid headers response %d: %s", w.Code, w.Body.String())
+	}
+	if w := serve("a.shop.example.com", "/missing", nil); w.Code != http.StatusNotFound || w.Body.String() != "shop not found" {
+		t.Errorf("Invalid host not found response %d: %s", w.Code, w.Body.String())
+	}
+
+	// Overlay routes of virtual host
+	err = routes.overlay([]byte(`
+hosts:
+- host: "*.shop.example.com"
+  routes:
+    GET /:
+      component:
+        name: tenant.home
+    not_found:
+      component:
+        name: site.home
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Remove default routes, requests matching no host are routed to default host
+	delete(routes, Route{Method: "GET", Path: "/"})
+	if h, err = routes.Construct(); err != nil {
+		t.Fatal(err)
+	}
+	if w := serve("b.shop.example.com", "/", nil); w.Body.String() != "tenant" {
+		t.Errorf("Invalid overlaid host response %d: %s", w.Code, w.Body.String())
+	}
+	if w := serve("b.shop.example.com", "/missing", nil); w.Code != http.StatusNotFound || w.Body.String() != "home" {
+		t.Errorf("Invalid overlaid host not found response %d: %s", w.Code, w.Body.String())
+	}
+	if w := serve("example.com", "/", nil); w.Code != http.StatusNotFound {
+		t.Errorf("Invalid unmatched host response %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -1,11 +1,17 @@
 package renderer
 
-import "github.com/rs/xhandler"
+import (
+	"github.com/rs/xhandler"
+
+	"tower.pro/renderer/sessions"
+)
 
 // New - New renderer web server API handler.
 // Context should have a compiler set with `compiler.NewContext`.
-// To always render HTML instead of JSON on Accept `application/json`
-// use `WithAlwaysHTML()` option.
+// Response type is negotiated using `Accept` header, it can be restricted
+// with `WithProduces()` option. To always render HTML instead of other types
+// use `WithAlwaysHTML()` option. Rendered components tree is written
+// as JSON when requested regardless of this option.
 //
 // Default options are:
 //
@@ -20,15 +26,34 @@ func construct(o *webOptions) xhandler.HandlerC {
 	var chain xhandler.Chain
 	chain.UseC(xhandler.CloseHandler)
 	chain.UseC(xhandler.TimeoutHandler(o.reqTimeout))
+	chain.UseC(optionsMiddleware(o))
+	if len(o.errors) != 0 {
+		chain.UseC(errorsMiddleware(o))
+	}
+	chain.UseC(NegotiateInContext)
 	chain.UseC(o.componentSetter)
 	chain.UseC(o.templateCtxSetter)
+	if len(o.errors) != 0 {
+		chain.UseC(errorsTemplateContext)
+	}
+	if o.sessions != nil {
+		chain.UseC(sessions.Middleware(o.sessions))
+	}
 	for _, m := range o.middlewares {
 		chain.UseC(m)
 	}
+	if o.cache != nil {
+		chain.UseC(cacheMiddleware(o))
+	}
+	if o.compression {
+		chain.UseC(compressMiddleware(o.compressMin))
+	}
+	chain.UseC(ResponseInContext)
 	chain.UseC(CompileInContext)
-	chain.UseC(RenderInContext)
-	if o.alwaysHTML {
-		return chain.HandlerCF(WriteRenderedHTML)
+	chain.UseC(ConditionalInContext)
+	if o.streaming {
+		return chain.HandlerCF(StreamRendered)
 	}
+	chain.UseC(RenderInContext)
 	return chain.HandlerCF(WriteRendered)
 }
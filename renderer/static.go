@@ -0,0 +1,138 @@
+package renderer
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/rs/xhandler"
+	"github.com/rs/xmux"
+	"golang.org/x/net/context"
+
+	"tower.pro/renderer/compiler"
+	"tower.pro/renderer/helpers"
+)
+
+// Static - Static files served from components storage.
+type Static struct {
+	// Dir - Directory in components storage, eq. `public`.
+	// Files are served from components directory if empty.
+	Dir string `json:"dir,omitempty" yaml:"dir,omitempty"`
+
+	// Param - Name of route parameter with file path, `path` by default.
+	Param string `json:"param,omitempty" yaml:"param,omitempty"`
+
+	// CacheControl - Value of `Cache-Control` header of files.
+	CacheControl string `json:"cache_control,omitempty" yaml:"cache_control,omitempty"`
+
+	// Extensions - Extensions of files which can be served.
+	// `DefaultStaticExtensions` are used if empty.
+	Extensions []string `json:"extensions,omitempty" yaml:"extensions,omitempty"`
+}
+
+// DefaultStaticExtensions - Extensions of static files served by default.
+// Styles, scripts and HTML are not listed, they can be components templates.
+var DefaultStaticExtensions = []string{
+	".png", ".jpg", ".jpeg", ".gif", ".webp", ".svg", ".ico",
+	".woff", ".woff2", ".ttf", ".otf", ".eot",
+	".mp3", ".mp4", ".webm", ".pdf",
+}
+
+// NewStatic - New static files web server handler. Serves files from
+// components storage with path from route parameter, with content type
+// detection, range and conditional requests. Components definitions,
+// their template files, `component.*` files and hidden files are never served.
+// Context should have a compiler set with `compiler.NewContext`.
+func NewStatic(static *Static, opts ...Option) xhandler.HandlerC {
+	o := constructOpts(opts...)
+	var chain xhandler.Chain
+	chain.UseC(xhandler.CloseHandler)
+	chain.UseC(xhandler.TimeoutHandler(o.reqTimeout))
+	chain.UseC(optionsMiddleware(o))
+	if len(o.errors) != 0 {
+		chain.UseC(errorsMiddleware(o))
+	}
+	if o.compression {
+		chain.UseC(compressMiddleware(o.compressMin))
+	}
+	for _, m := range o.middlewares {
+		chain.UseC(m)
+	}
+	return chain.HandlerC(static)
+}
+
+// ServeHTTPC - Serves static file.
+func (static *Static) ServeHTTPC(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	param := static.Param
+	if param == "" {
+		param = "path"
+	}
+	name := path.Clean("/" + xmux.Params(ctx).Get(param))
+	if !static.allowed(name) {
+		helpers.WriteError(w, r, http.StatusNotFound, fmt.Sprintf("file %q not found", name))
+		return
+	}
+
+	comp, ok := compiler.FromContext(ctx)
+	if !ok {
+		helpers.WriteError(w, r, http.StatusInternalServerError, "compiler not found")
+		return
+	}
+	filename := path.Join(static.Dir, name)
+	if comp.TemplateFile(filename) {
+		helpers.WriteError(w, r, http.StatusNotFound, fmt.Sprintf("file %q not found", name))
+		return
+	}
+	f, err := comp.Storage.Open(filename)
+	if err != nil {
+		if os.IsNotExist(err) || os.IsPermission(err) {
+			helpers.WriteError(w, r, http.StatusNotFound, fmt.Sprintf("file %q not found", name))
+		} else {
+			helpers.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		helpers.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if info.IsDir() {
+		helpers.WriteError(w, r, http.StatusNotFound, fmt.Sprintf("file %q not found", name))
+		return
+	}
+
+	if static.CacheControl != "" {
+		w.Header().Set("Cache-Control", static.CacheControl)
+	}
+	w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()))
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
+
+// allowed - Returns true if file can be served.
+func (static *Static) allowed(name string) bool {
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") {
+			return false
+		}
+	}
+	base := path.Base(name)
+	ext := strings.ToLower(path.Ext(base))
+	if ext == ".yaml" || ext == ".yml" || strings.HasPrefix(base, "component.") {
+		return false
+	}
+	extensions := static.Extensions
+	if len(extensions) == 0 {
+		extensions = DefaultStaticExtensions
+	}
+	for _, e := range extensions {
+		if strings.ToLower(e) == ext {
+			return true
+		}
+	}
+	return false
+}
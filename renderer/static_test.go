@@ -0,0 +1,146 @@
+package renderer
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"tower.pro/renderer/compiler"
+	"tower.pro/renderer/storage"
+)
+
+func TestStatic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "renderer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"public/logo.svg":               "<svg></svg>",
+		"public/.secret.svg":            "secret",
+		"public/component.yaml":         "main: template://secret",
+		"public/fonts/font.woff2":       "0123456789",
+		"site/header/template.css":      "body {}",
+		"site/header/extra.css":         "p {}",
+		"site/header/component.yaml":    "main: file://template.html\nstyles:\n- file://template.css",
+		"site/header/template.html":     "<header></header>",
+		"site/not_found/component.yaml": "main: template://<p>missing</p>",
+	}
+	for name, body := range files {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(filename), 0755)
+		if err := ioutil.WriteFile(filename, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	outside, err := ioutil.TempFile("", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	outside.Close()
+	defer os.Remove(outside.Name())
+	if err := os.Symlink(outside.Name(), filepath.Join(dir, "public", "link.svg")); err != nil {
+		t.Fatal(err)
+	}
+	s, err := storage.New(storage.WithDir(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := compiler.NewContext(context.Background(), compiler.New(s))
+
+	routes, err := parseRoutes([]byte(`
+errors:
+  404: site.not_found
+GET /static/*path:
+  static:
+    dir: public
+    cache_control: public, max-age=3600
+GET /assets/*file:
+  static:
+    param: file
+GET /files/*path:
+  static:
+    extensions: [.html, .css]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := routes.Construct(WithCompression(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve := func(path string, headers map[string]string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("GET", path, nil)
+		for name, value := range headers {
+			r.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTPC(ctx, w, r)
+		return w
+	}
+
+	w := serve("/static/logo.svg", nil)
+	if w.Code != http.StatusOK || w.Body.String() != "<svg></svg>" || w.Header().Get("Content-Type") != "image/svg+xml" {
+		t.Errorf("Invalid response %d %v: %s", w.Code, w.Header(), w.Body.String())
+	}
+	if w.Header().Get("Cache-Control") != "public, max-age=3600" || w.Header().Get("Last-Modified") == "" {
+		t.Errorf("Invalid cache headers %v", w.Header())
+	}
+
+	if w := serve("/static/logo.svg", map[string]string{"If-None-Match": w.Header().Get("ETag")}); w.Code != http.StatusNotModified {
+		t.Errorf("Expected not modified response %d", w.Code)
+	}
+	if w := serve("/static/fonts/font.woff2", map[string]string{"Range": "bytes=2-4"}); w.Code != http.StatusPartialContent || w.Body.String() != "234" {
+		t.Errorf("Invalid range response %d: %s", w.Code, w.Body.String())
+	}
+	w = serve("/static/logo.svg", map[string]string{"Range": "bytes=2-4", "Accept-Encoding": "gzip"})
+	if w.Code != http.StatusPartialContent || w.Body.String() != "vg>" || w.Header().Get("Content-Encoding") != "" {
+		t.Errorf("Invalid compressed range response %d %v: %s", w.Code, w.Header(), w.Body.String())
+	}
+
+	if w := serve("/files/site/header/extra.css", nil); w.Code != http.StatusOK || w.Body.String() != "p {}" {
+		t.Errorf("Invalid response %d: %s", w.Code, w.Body.String())
+	}
+	if w := serve("/static/missing.svg", map[string]string{"Accept": "text/html"}); w.Code != http.StatusNotFound || w.Body.String() != "<p>missing</p>" {
+		t.Errorf("Expected error page %d: %s", w.Code, w.Body.String())
+	}
+
+	for _, path := range []string{
+		"/static/.secret.svg",
+		"/static/link.svg",
+		"/files/site/header/template.css",
+		"/files/site/header/template.html",
+		"/static/component.yaml",
+		"/static/missing.svg",
+		"/static/fonts",
+		"/static/../site/header/template.css",
+		"/assets/site/header/template.css",
+	} {
+		if w := serve(path, nil); w.Code != http.StatusNotFound {
+			t.Errorf("Expected not found %s: %d %s", path, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestStaticCombined(t *testing.T) {
+	routes, err := parseRoutes([]byte(`
+GET /static/*path:
+  static:
+    dir: public
+  status: 201
+  component:
+    name: site.page
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := routes.Construct(); err == nil || !strings.Contains(err.Error(), "component, status") {
+		t.Errorf("Expected combined static error, got %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+package renderer
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/rs/xhandler"
+	"golang.org/x/net/context"
+
+	"tower.pro/renderer/helpers"
+)
+
+// Writer - Rendered component response writer selected by `Accept` header.
+type Writer struct {
+	// Name - Name of the writer, eq. `html`.
+	Name string
+
+	// Type - Media type of the response, eq. `text/html`.
+	Type string
+
+	// Profile - Media type profile, eq. `fragment`.
+	// Writer with profile is selected only when requested with the profile.
+	Profile string
+
+	// Write - Writes rendered component from context to response writer.
+	Write xhandler.HandlerFuncC
+}
+
+// writers - Registered response writers.
+// When requested types are equally acceptable, first writer is selected.
+var writers = []*Writer{
+	{Name: "html", Type: "text/html", Write: WriteRenderedHTML},
+	{Name: "fragment", Type: "text/html", Profile: "fragment", Write: WriteRenderedFragment},
+	{Name: "json", Type: "application/json", Write: WriteRenderedJSON},
+	{Name: "tree", Type: "application/json", Profile: "tree", Write: WriteRenderedJSON},
+	{Name: "yaml", Type: "application/x-yaml", Write: WriteRenderedYAML},
+	{Name: "text", Type: "text/plain", Write: WriteRenderedText},
+}
+
+// RegisterWriter - Registers a response writer. Replaces writer with the same name.
+// It's not safe for concurrent use and should be called before handlers are constructed.
+func RegisterWriter(wr *Writer) {
+	for index, w := range writers {
+		if w.Name == wr.Name {
+			writers[index] = wr
+			return
+		}
+	}
+	writers = append(writers, wr)
+}
+
+// MediaType - Returns media type of the writer with profile if any.
+func (wr *Writer) MediaType() string {
+	if wr.Profile == "" {
+		return wr.Type
+	}
+	return wr.Type + "; profile=" + wr.Profile
+}
+
+// quality - Returns quality of the most specific media range matching the writer.
+func (wr *Writer) quality(ranges []helpers.MediaRange) (q float64) {
+	specificity := -1
+	for _, m := range ranges {
+		if m.Params["profile"] != wr.Profile {
+			continue
+		}
+		if s := m.Match(wr.Type); s > specificity {
+			specificity, q = s, m.Q
+		}
+	}
+	return
+}
+
+type writerCtxKey struct{}
+
+var writerKey = writerCtxKey{}
+
+// NegotiateInContext - Selects response writer accepted by request `Accept` header
+// and allowed by options. Responds with `406 Not Acceptable` if there is none.
+// Stores result in context to be used by `WriteRendered`.
+func NegotiateInContext(next xhandler.HandlerC) xhandler.HandlerC {
+	return xhandler.HandlerFuncC(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		wr, ok := acceptedWriter(ctx, w, r)
+		if !ok {
+			helpers.WriteError(w, r, http.StatusNotAcceptable, "not acceptable")
+			return
+		}
+		ctx = context.WithValue(ctx, writerKey, wr)
+		next.ServeHTTPC(ctx, w, r)
+	})
+}
+
+// acceptedWriter - Returns response writer from context or negotiates it
+// and sets `Vary: Accept` header if not negotiated yet.
+func acceptedWriter(ctx context.Context, w http.ResponseWriter, r *http.Request) (*Writer, bool) {
+	if wr, ok := ctx.Value(writerKey).(*Writer); ok {
+		return wr, true
+	}
+	w.Header().Add("Vary", "Accept")
+	return negotiateWriter(optionsFromContext(ctx), r)
+}
+
+// negotiateWriter - Returns response writer accepted by request.
+// Rendered tree and fragment can be also requested with query parameters.
+// When responding only with HTML other types are not negotiated.
+func negotiateWriter(o *webOptions, r *http.Request) (*Writer, bool) {
+	query := r.URL.Query()
+	if tree, _ := strconv.ParseBool(query.Get("tree")); tree {
+		return o.writer("tree")
+	}
+	if fragment, _ := strconv.ParseBool(query.Get("fragment")); fragment {
+		return o.writer("fragment")
+	}
+
+	var (
+		best    *Writer
+		quality float64
+		ranges  = helpers.ParseAccept(r.Header.Get("Accept"))
+	)
+	for _, wr := range writers {
+		if !o.produces(wr) || (o.alwaysHTML && wr.Type != "text/html" && wr.Name != "tree") {
+			continue
+		}
+		if q := wr.quality(ranges); q > quality {
+			best, quality = wr, q
+		}
+	}
+	if best == nil && o.alwaysHTML {
+		return o.writer("html")
+	}
+	return best, best != nil
+}
+
+// writer - Returns writer by name if it's allowed.
+func (o *webOptions) writer(name string) (*Writer, bool) {
+	for _, wr := range writers {
+		if wr.Name == name {
+			return wr, o.produces(wr)
+		}
+	}
+	return nil, false
+}
+
+// produces - Returns true if responses can be written with the writer.
+// Writer is allowed when its media type with or without profile is allowed.
+func (o *webOptions) produces(wr *Writer) bool {
+	if len(o.produce) == 0 {
+		return true
+	}
+	return helpers.Contain(o.produce, wr.Type) || helpers.Contain(o.produce, wr.MediaType())
+}
@@ -0,0 +1,196 @@
+package renderer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rs/xhandler"
+	"golang.org/x/net/context"
+
+	"tower.pro/renderer/components"
+	"tower.pro/renderer/template"
+)
+
+func TestNegotiateWriter(t *testing.T) {
+	tests := []struct {
+		accept string
+		url    string
+		opts   []Option
+		writer string
+	}{
+		{accept: "", writer: "html"},
+		{accept: "*/*", writer: "html"},
+		{accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", writer: "html"},
+		{accept: "application/json", writer: "json"},
+		{accept: "text/html;q=0.5, application/json", writer: "json"},
+		{accept: "application/json;q=0.2, application/x-yaml;q=0.8", writer: "yaml"},
+		{accept: "text/*", writer: "html"},
+		{accept: "text/plain, */*;q=0.1", writer: "text"},
+		{accept: "text/html; profile=fragment", writer: "fragment"},
+		{accept: "application/json; profile=tree", writer: "tree"},
+		{accept: "*/*", url: "/?tree=true", writer: "tree"},
+		{accept: "*/*;q=0, application/json;q=0", writer: ""},
+		{accept: "image/png", writer: ""},
+		{accept: "application/json", opts: []Option{WithAlwaysHTML()}, writer: "html"},
+		{accept: "application/json; profile=tree", opts: []Option{WithAlwaysHTML()}, writer: "tree"},
+		{accept: "application/json", opts: []Option{WithProduces("text/html")}, writer: ""},
+		{accept: "text/html; profile=fragment", opts: []Option{WithProduces("text/html")}, writer: "fragment"},
+		{accept: "text/html", opts: []Option{WithProduces("text/html; profile=fragment")}, writer: ""},
+		{accept: "*/*", opts: []Option{WithProduces("application/json")}, writer: "json"},
+	}
+	for _, test := range tests {
+		url := test.url
+		if url == "" {
+			url = "/"
+		}
+		r, _ := http.NewRequest("GET", url, nil)
+		r.Header.Set("Accept", test.accept)
+		wr, ok := negotiateWriter(constructOpts(test.opts...), r)
+		name := ""
+		if ok {
+			name = wr.Name
+		}
+		if name != test.writer {
+			t.Errorf("Accept %q: expected %q writer, got %q", test.accept, test.writer, name)
+		}
+	}
+}
+
+func TestWriteRenderedFragment(t *testing.T) {
+	res := &components.Rendered{
+		Body:    "<html><head></head><body><p>test</p></body></html>",
+		Styles:  []string{"/style.css", "p{color:red}"},
+		Scripts: []string{"https://cdn.example.com/app.js", "run()"},
+		Head:    &components.Head{Title: "Test"},
+	}
+	ctx := components.NewRenderedContext(context.Background(), res)
+	r, _ := http.NewRequest("GET", "/?fragment=true", nil)
+	w := httptest.NewRecorder()
+	WriteRenderedFragment(ctx, w, r)
+
+	expected := `<style type="text/css">p{color:red}</style>` + res.Body + `<script type="text/javascript">run()</script>`
+	if w.Code != http.StatusOK || w.Body.String() != expected {
+		t.Fatalf("Invalid fragment %d: %s", w.Code, w.Body.String())
+	}
+	if body := w.Body.String(); strings.Contains(body, "<title>") || strings.Contains(body, "/style.css") {
+		t.Errorf("Fragment contains head entries: %s", body)
+	}
+	if styles := w.Header()["X-Render-Style"]; !reflect.DeepEqual(styles, []string{"/style.css"}) {
+		t.Errorf("Invalid X-Render-Style header: %v", styles)
+	}
+	if scripts := w.Header()["X-Render-Script"]; !reflect.DeepEqual(scripts, []string{"https://cdn.example.com/app.js"}) {
+		t.Errorf("Invalid X-Render-Script header: %v", scripts)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("Invalid Content-Type: %q", ct)
+	}
+}
+
+func TestWriteRenderedHTMLPreload(t *testing.T) {
+	res := &components.Rendered{
+		Body:      "<html><head></head><body></body></html>",
+		Styles:    []string{"/main.css", "/print.css"},
+		Scripts:   []string{"/app.js"},
+		NoPreload: []string{"/print.css"},
+	}
+	serve := func(opts ...Option) *httptest.ResponseRecorder {
+		h := optionsMiddleware(constructOpts(opts...))(xhandler.HandlerFuncC(WriteRenderedHTML))
+		r, _ := http.NewRequest("GET", "/", nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTPC(components.NewRenderedContext(context.Background(), res), w, r)
+		return w
+	}
+
+	w := serve(WithPreload())
+	expected := []string{"</main.css>; rel=preload; as=style", "</app.js>; rel=preload; as=script"}
+	if links := w.Header()["Link"]; !reflect.DeepEqual(links, expected) {
+		t.Errorf("Invalid Link headers: %v", links)
+	}
+	if !strings.Contains(w.Body.String(), `<link rel="preload" href="/app.js" as="script" />`) {
+		t.Errorf("Preload tags not found: %s", w.Body.String())
+	}
+
+	if w := serve(); len(w.Header()["Link"]) != 0 || strings.Contains(w.Body.String(), `rel="preload"`) {
+		t.Errorf("Preloaded without preloading %v: %s", w.Header()["Link"], w.Body.String())
+	}
+}
+
+func TestRenderInContextTrace(t *testing.T) {
+	main, err := template.FromString("<p>{{ title }}</p>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &components.Compiled{Component: &components.Component{Name: "test"}, Main: main}
+	serve := func(opts ...Option) (*httptest.ResponseRecorder, *components.Rendered) {
+		var res *components.Rendered
+		h := optionsMiddleware(constructOpts(opts...))(RenderInContext(
+			xhandler.HandlerFuncC(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+				res, _ = components.RenderedFromContext(ctx)
+			})))
+		ctx := components.NewCompiledContext(context.Background(), c)
+		ctx = components.NewTemplateContext(ctx, template.Context{"title": "test"})
+		r, _ := http.NewRequest("GET", "/", nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTPC(ctx, w, r)
+		return w, res
+	}
+
+	w, res := serve(WithDebug())
+	tr := new(components.Trace)
+	if err := json.Unmarshal([]byte(w.Header().Get("X-Render-Trace")), tr); err != nil {
+		t.Fatalf("Invalid X-Render-Trace header %q: %v", w.Header().Get("X-Render-Trace"), err)
+	}
+	if tr.Name != "test" || tr.Render <= 0 || tr.Size != len("<p>test</p>") {
+		t.Errorf("Invalid render trace: %#v", tr)
+	}
+	if res == nil || res.Trace == nil {
+		t.Errorf("Expected trace in rendered result in debug mode")
+	}
+
+	if w, res = serve(WithTracing()); w.Header().Get("X-Render-Trace") != "" || res == nil || res.Trace != nil {
+		t.Errorf("Render trace written without debug mode: %q", w.Header().Get("X-Render-Trace"))
+	}
+}
+
+func TestStreamRenderedFlusher(t *testing.T) {
+	ctx, cleanup := testContext(t, map[string]string{
+		"site.root": "main: template://<html><head></head><body>{{ children }}</body></html>",
+		"site.page": "main: template://<p>page</p>\nextends: site.root",
+	})
+	defer cleanup()
+
+	routes, err := parseRoutes([]byte(`
+GET /:
+  status: 201
+  component:
+    name: site.page
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := routes.Construct(WithStreaming(), WithCompression(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve := func(w http.ResponseWriter) {
+		r, _ := http.NewRequest("GET", "/", nil)
+		h.ServeHTTPC(ctx, w, r)
+	}
+
+	w := httptest.NewRecorder()
+	serve(w)
+	if !w.Flushed || w.Code != http.StatusCreated || w.Header().Get("ETag") != "" || !strings.Contains(w.Body.String(), "<p>page</p>") {
+		t.Errorf("Expected streamed response, got %d %v: %s", w.Code, w.Flushed, w.Body.String())
+	}
+
+	// Response writer which can't be flushed
+	w = httptest.NewRecorder()
+	serve(struct{ http.ResponseWriter }{w})
+	if w.Code != http.StatusCreated || w.Header().Get("ETag") == "" || w.Body.String() != "<html><head></head><body><p>page</p></body></html>" {
+		t.Errorf("Expected rendered response, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,151 @@
+package sessions
+
+import (
+	"net/http"
+
+	"github.com/golang/glog"
+	"github.com/rs/xhandler"
+	"golang.org/x/net/context"
+
+	"tower.pro/renderer/components"
+	"tower.pro/renderer/helpers"
+	"tower.pro/renderer/middlewares"
+	"tower.pro/renderer/options"
+)
+
+// Middleware - Reads session from request cookie, stores it in context
+// and its values in template context under `session` key.
+// Session cookie is written before response if session was modified.
+func Middleware(store *Store) middlewares.Handler {
+	return middlewares.ToHandler(func(ctx context.Context, w http.ResponseWriter, r *http.Request, next xhandler.HandlerC) {
+		s := store.Read(r)
+		ctx = NewContext(ctx, s)
+		ctx = components.WithTemplateKey(ctx, "session", s.Values)
+		sw := &sessionWriter{ResponseWriter: w, store: store, session: s}
+		next.ServeHTTPC(ctx, helpers.WithFlusher(sw, w, sw.flush), r)
+		sw.writeSession()
+	})
+}
+
+// sessionWriter - Response writer writing session cookie
+// before response headers are written.
+type sessionWriter struct {
+	http.ResponseWriter
+	store   *Store
+	session *Session
+	written bool
+}
+
+func (sw *sessionWriter) writeSession() {
+	if sw.written {
+		return
+	}
+	sw.written = true
+	if !sw.session.modified {
+		return
+	}
+	if err := sw.store.Write(sw.ResponseWriter, sw.session); err != nil {
+		glog.Warningf("[sessions] write error: %v", err)
+	}
+}
+
+func (sw *sessionWriter) WriteHeader(code int) {
+	sw.writeSession()
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *sessionWriter) Write(body []byte) (int, error) {
+	sw.writeSession()
+	return sw.ResponseWriter.Write(body)
+}
+
+// flush - Writes session cookie and flushes response.
+func (sw *sessionWriter) flush(f http.Flusher) {
+	sw.writeSession()
+	f.Flush()
+}
+
+var optSessionValues = &options.Option{
+	ID:     "renderer.session.values",
+	Name:   "values",
+	Type:   options.TypeMap,
+	Short:  "Values to set in session",
+	Always: true,
+}
+
+var optSessionKeys = &options.Option{
+	ID:     "renderer.session.keys",
+	Name:   "keys",
+	Type:   options.TypeStringList,
+	Short:  "Keys to delete from session",
+	Always: true,
+}
+
+func init() {
+	middlewares.Register(middlewares.Descriptor{
+		Name:        "renderer.session.set",
+		Description: "Sets values in session.",
+		Options:     []*options.Option{optSessionValues},
+	}, sessionSet)
+
+	middlewares.Register(middlewares.Descriptor{
+		Name:        "renderer.session.delete",
+		Description: "Deletes values from session.",
+		Options:     []*options.Option{optSessionKeys},
+	}, sessionDelete)
+
+	middlewares.Register(middlewares.Descriptor{
+		Name:        "renderer.session.clear",
+		Description: "Deletes all values from session.",
+	}, sessionClear)
+}
+
+func sessionSet(opts middlewares.Options) (middlewares.Handler, error) {
+	return withSession(func(ctx context.Context, s *Session) error {
+		values, err := opts.Map(ctx, optSessionValues)
+		if err != nil {
+			return err
+		}
+		for key, value := range values {
+			s.Set(key, value)
+		}
+		return nil
+	}), nil
+}
+
+func sessionDelete(opts middlewares.Options) (middlewares.Handler, error) {
+	return withSession(func(ctx context.Context, s *Session) error {
+		keys, err := opts.StringList(ctx, optSessionKeys)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			s.Delete(key)
+		}
+		return nil
+	}), nil
+}
+
+func sessionClear(_ middlewares.Options) (middlewares.Handler, error) {
+	return withSession(func(_ context.Context, s *Session) error {
+		s.Clear()
+		return nil
+	}), nil
+}
+
+// withSession - Creates middleware modifying session from context.
+// Responds with `500 Internal Server Error` if sessions are not enabled.
+func withSession(fn func(context.Context, *Session) error) middlewares.Handler {
+	return middlewares.ToHandler(func(ctx context.Context, w http.ResponseWriter, r *http.Request, next xhandler.HandlerC) {
+		s, ok := FromContext(ctx)
+		if !ok {
+			helpers.WriteError(w, r, http.StatusInternalServerError, "sessions are not enabled")
+			return
+		}
+		if err := fn(ctx, s); err != nil {
+			helpers.WriteError(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+		next.ServeHTTPC(ctx, w, r)
+	})
+}
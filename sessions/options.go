@@ -0,0 +1,72 @@
+package sessions
+
+import "time"
+
+// Option - Sessions store option setter.
+type Option func(*storeOptions)
+
+type storeOptions struct {
+	name     string
+	path     string
+	domain   string
+	maxAge   time.Duration
+	secure   bool
+	encrypt  bool
+	httpOnly bool
+}
+
+func newOptions(opts ...Option) (o *storeOptions) {
+	o = &storeOptions{
+		name:     "session",
+		path:     "/",
+		maxAge:   30 * 24 * time.Hour,
+		httpOnly: true,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return
+}
+
+// WithName - Sets session cookie name, `session` by default.
+func WithName(name string) Option {
+	return func(o *storeOptions) {
+		o.name = name
+	}
+}
+
+// WithPath - Sets session cookie path, `/` by default.
+func WithPath(path string) Option {
+	return func(o *storeOptions) {
+		o.path = path
+	}
+}
+
+// WithDomain - Sets session cookie domain.
+func WithDomain(domain string) Option {
+	return func(o *storeOptions) {
+		o.domain = domain
+	}
+}
+
+// WithMaxAge - Sets session expiration, 30 days by default.
+func WithMaxAge(maxAge time.Duration) Option {
+	return func(o *storeOptions) {
+		o.maxAge = maxAge
+	}
+}
+
+// WithSecure - Sets session cookie to be sent only over HTTPS.
+func WithSecure(secure bool) Option {
+	return func(o *storeOptions) {
+		o.secure = secure
+	}
+}
+
+// WithEncryption - Enables encryption of session cookie,
+// by default it's only signed and values can be read by client.
+func WithEncryption(encrypt bool) Option {
+	return func(o *storeOptions) {
+		o.encrypt = encrypt
+	}
+}
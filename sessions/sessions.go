@@ -0,0 +1,249 @@
+package sessions
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"tower.pro/renderer/helpers"
+	"tower.pro/renderer/template"
+)
+
+// Store - Sessions store keeping session values in signed
+// or encrypted cookies.
+type Store struct {
+	opts *storeOptions
+	keys [][]byte
+}
+
+// New - Creates new sessions store. First key is used to sign or encrypt
+// new cookies, all keys are used to read cookies so keys can be rotated.
+func New(keys [][]byte, opts ...Option) (*Store, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("sessions: at least one key is required")
+	}
+	for _, key := range keys {
+		if len(key) < 16 {
+			return nil, errors.New("sessions: key should be at least 16 bytes long")
+		}
+	}
+	return &Store{opts: newOptions(opts...), keys: keys}, nil
+}
+
+// Session - Session values.
+type Session struct {
+	// Values - Session values, available in template context under `session` key.
+	Values template.Context
+
+	modified bool
+}
+
+// Get - Gets session value.
+func (s *Session) Get(key string) interface{} {
+	return s.Values[key]
+}
+
+// Set - Sets session value.
+func (s *Session) Set(key string, value interface{}) {
+	s.Values[key] = value
+	s.modified = true
+}
+
+// Delete - Deletes session value.
+func (s *Session) Delete(key string) {
+	delete(s.Values, key)
+	s.modified = true
+}
+
+// Clear - Deletes all session values.
+func (s *Session) Clear() {
+	for key := range s.Values {
+		delete(s.Values, key)
+	}
+	s.modified = true
+}
+
+// Modified - Returns true if session was modified.
+func (s *Session) Modified() bool {
+	return s.modified
+}
+
+type sessionCtxKey struct{}
+
+var sessionKey = sessionCtxKey{}
+
+// NewContext - Creates a context with session.
+func NewContext(ctx context.Context, s *Session) context.Context {
+	return context.WithValue(ctx, sessionKey, s)
+}
+
+// FromContext - Retrieves session from context.
+func FromContext(ctx context.Context) (s *Session, ok bool) {
+	s, ok = ctx.Value(sessionKey).(*Session)
+	return
+}
+
+// Read - Reads session from request cookie.
+// Returns empty session if cookie is not set, invalid or expired.
+func (store *Store) Read(r *http.Request) *Session {
+	s := &Session{Values: make(template.Context)}
+	cookie, err := r.Cookie(store.opts.name)
+	if err != nil {
+		return s
+	}
+	if values, err := store.decode(cookie.Value); err == nil {
+		s.Values = values
+	}
+	return s
+}
+
+// Write - Writes session cookie, expires cookie if session is empty.
+func (store *Store) Write(w http.ResponseWriter, s *Session) (err error) {
+	cookie := &http.Cookie{
+		Name:     store.opts.name,
+		Path:     store.opts.path,
+		Domain:   store.opts.domain,
+		Secure:   store.opts.secure,
+		HttpOnly: store.opts.httpOnly,
+	}
+	if len(s.Values) == 0 {
+		cookie.MaxAge = -1
+	} else {
+		cookie.Value, err = store.encode(s.Values)
+		if err != nil {
+			return
+		}
+		cookie.MaxAge = int(store.opts.maxAge / time.Second)
+	}
+	http.SetCookie(w, cookie)
+	return
+}
+
+// payload - Encoded session content.
+type payload struct {
+	Time   int64                  `json:"t"`
+	Values map[string]interface{} `json:"v"`
+}
+
+func (store *Store) encode(values template.Context) (_ string, err error) {
+	data, err := json.Marshal(&payload{Time: time.Now().Unix(), Values: values})
+	if err != nil {
+		return
+	}
+	key := store.keys[0]
+	if !store.opts.encrypt {
+		return encodeString(data) + "." + encodeString(store.sign(key, data)), nil
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return
+	}
+	return encodeString(gcm.Seal(nonce, nonce, data, []byte(store.opts.name))), nil
+}
+
+func (store *Store) decode(value string) (_ template.Context, err error) {
+	var data []byte
+	for _, key := range store.keys {
+		if store.opts.encrypt {
+			data, err = store.decrypt(key, value)
+		} else {
+			data, err = store.verify(key, value)
+		}
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return
+	}
+	var p payload
+	if err = json.Unmarshal(data, &p); err != nil {
+		return
+	}
+	if time.Since(time.Unix(p.Time, 0)) > store.opts.maxAge {
+		return nil, errors.New("sessions: session expired")
+	}
+	values, err := helpers.CleanDeep(p.Values)
+	if err != nil {
+		return
+	}
+	res, _ := values.(template.Context)
+	if res == nil {
+		res = make(template.Context)
+	}
+	return res, nil
+}
+
+func (store *Store) sign(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(store.opts.name))
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func (store *Store) verify(key []byte, value string) (data []byte, err error) {
+	parts := strings.Split(value, ".")
+	if len(parts) != 2 {
+		return nil, errors.New("sessions: invalid cookie")
+	}
+	data, err = decodeString(parts[0])
+	if err != nil {
+		return
+	}
+	sig, err := decodeString(parts[1])
+	if err != nil {
+		return
+	}
+	if !hmac.Equal(sig, store.sign(key, data)) {
+		return nil, errors.New("sessions: invalid signature")
+	}
+	return
+}
+
+func (store *Store) decrypt(key []byte, value string) (_ []byte, err error) {
+	data, err := decodeString(value)
+	if err != nil {
+		return
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("sessions: invalid cookie")
+	}
+	nonce, data := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, data, []byte(store.opts.name))
+}
+
+// newGCM - Creates AES-GCM cipher with key derived from session key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	sum := sha256.Sum256(key)
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func encodeString(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeString(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(value)
+}
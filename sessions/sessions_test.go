@@ -0,0 +1,103 @@
+package sessions
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rs/xhandler"
+	"golang.org/x/net/context"
+
+	"tower.pro/renderer/components"
+	"tower.pro/renderer/middlewares"
+	"tower.pro/renderer/template"
+)
+
+var (
+	oldKey = []byte("0123456789abcdef-old")
+	newKey = []byte("0123456789abcdef-new")
+)
+
+func TestStore(t *testing.T) {
+	for _, encrypt := range []bool{false, true} {
+		old, err := New([][]byte{oldKey}, WithEncryption(encrypt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		value, err := old.encode(template.Context{"user": template.Context{"id": "1"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Rotated keys read cookies signed with old key
+		store, _ := New([][]byte{newKey, oldKey}, WithEncryption(encrypt))
+		values, err := store.decode(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if values.Get("user.id") != "1" {
+			t.Errorf("Invalid session values %#v", values)
+		}
+
+		other, _ := New([][]byte{newKey}, WithEncryption(encrypt))
+		if _, err := other.decode(value); err == nil {
+			t.Errorf("Expected invalid cookie error (encrypt: %v)", encrypt)
+		}
+		if _, err := store.decode(value[:len(value)-2]); err == nil {
+			t.Errorf("Expected tampered cookie error (encrypt: %v)", encrypt)
+		}
+
+		expired, _ := New([][]byte{oldKey}, WithEncryption(encrypt), WithMaxAge(-time.Second))
+		if _, err := expired.decode(value); err == nil {
+			t.Errorf("Expected expired cookie error (encrypt: %v)", encrypt)
+		}
+	}
+
+	if _, err := New([][]byte{[]byte("short")}); err == nil {
+		t.Error("Expected short key error")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	store, _ := New([][]byte{newKey})
+	set, err := middlewares.Construct(&middlewares.Middleware{
+		Name:    "renderer.session.set",
+		Options: template.Context{"values": template.Context{"user": "john"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clearSession, err := middlewares.Construct(&middlewares.Middleware{Name: "renderer.session.clear"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var session template.Context
+	serve := func(m middlewares.Handler, cookie *http.Cookie) *httptest.ResponseRecorder {
+		h := Middleware(store)(m(xhandler.HandlerFuncC(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			v, _ := components.TemplateValue(ctx, "session")
+			session, _ = v.(template.Context)
+			w.Write([]byte("ok"))
+		})))
+		r, _ := http.NewRequest("GET", "/", nil)
+		if cookie != nil {
+			r.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTPC(components.NewTemplateContext(context.Background(), template.Context{}), w, r)
+		return w
+	}
+
+	w := serve(set, nil)
+	cookies := (&http.Response{Header: w.Header()}).Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "session" || session["user"] != "john" {
+		t.Fatalf("Invalid session response %v %#v", w.Header(), session)
+	}
+
+	w = serve(clearSession, cookies[0])
+	cleared := (&http.Response{Header: w.Header()}).Cookies()
+	if len(cleared) != 1 || cleared[0].MaxAge != -1 || len(session) != 0 {
+		t.Errorf("Expected cleared session %v %#v", w.Header(), session)
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v2"
 
@@ -29,6 +31,7 @@ func New(opts ...Option) (s *Storage, err error) {
 			components: cache.New(o.cacheExpiration, o.cleanupInterval),
 			templates:  cache.New(o.cacheExpiration, o.cleanupInterval),
 			files:      cache.New(o.cacheExpiration, o.cleanupInterval),
+			modTimes:   cache.New(o.cacheExpiration, o.cleanupInterval),
 		},
 	}, nil
 }
@@ -45,6 +48,7 @@ type storageCache struct {
 	components *cache.Cache
 	templates  *cache.Cache
 	files      *cache.Cache
+	modTimes   *cache.Cache
 }
 
 // Text - Returns file content as Template interface.
@@ -86,8 +90,7 @@ func (s *Storage) Component(name string) (c *components.Component, err error) {
 }
 
 func (s *Storage) component(name string) (c *components.Component, err error) {
-	path := strings.Replace(name, ".", string(os.PathSeparator), -1)
-	path = filepath.Join(s.opts.dirname, path, "component.yaml")
+	path := s.componentPath(name)
 	if tmp, ok := s.cache.components.Get(path); ok {
 		return tmp.(*components.Component), nil
 	}
@@ -107,16 +110,78 @@ func (s *Storage) component(name string) (c *components.Component, err error) {
 	return
 }
 
+// componentPath - Returns path of a component definition by name.
+func (s *Storage) componentPath(name string) string {
+	path := strings.Replace(name, ".", string(os.PathSeparator), -1)
+	return filepath.Join(s.opts.dirname, path, "component.yaml")
+}
+
+// Open - Opens file by slash-separated path in storage directory.
+// Path is cleaned so it can't point outside of storage directory.
+// Symbolic links are followed only if they point inside storage directory.
+func (s *Storage) Open(name string) (*os.File, error) {
+	name = filepath.Join(s.opts.dirname, filepath.FromSlash(path.Clean("/"+name)))
+	real, err := filepath.EvalSymlinks(name)
+	if err != nil {
+		return nil, err
+	}
+	root, err := filepath.EvalSymlinks(s.opts.dirname)
+	if err != nil {
+		return nil, err
+	}
+	if real != root && !strings.HasPrefix(real, root+string(os.PathSeparator)) {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrPermission}
+	}
+	return os.Open(real)
+}
+
 // Close - Destroys caches and stops watching for changes.
 func (s *Storage) Close() (err error) {
 	s.FlushCache()
 	return
 }
 
+// ModTime - Returns modification time of a file by path.
+// Returns zero time if file doesn't exist. Result is cached.
+func (s *Storage) ModTime(path string) time.Time {
+	return s.modTime(filepath.Join(s.opts.dirname, path))
+}
+
+// ComponentModTime - Returns modification time of a component definition by name.
+// Returns zero time if component doesn't exist in storage.
+func (s *Storage) ComponentModTime(name string) time.Time {
+	return s.modTime(s.componentPath(name))
+}
+
+func (s *Storage) modTime(path string) (t time.Time) {
+	if f, ok := s.cache.files.Get(path); ok {
+		return f.(*file).modTime
+	}
+	if tmp, ok := s.cache.modTimes.Get(path); ok {
+		return tmp.(time.Time)
+	}
+	// Not existing files are cached with zero time
+	if info, err := os.Stat(path); err == nil {
+		t = info.ModTime()
+	}
+	s.cache.modTimes.Set(path, t, cache.DefaultExpiration)
+	return
+}
+
+// file - Cached file content.
+type file struct {
+	body    []byte
+	modTime time.Time
+}
+
 // read - reads file content or returns cached byte array
 func (s *Storage) read(path string, removeWhitespace bool) (body []byte, err error) {
-	if b, ok := s.cache.files.Get(path); ok {
-		return b.([]byte), nil
+	if f, ok := s.cache.files.Get(path); ok {
+		return f.(*file).body, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return
 	}
 	body, err = ioutil.ReadFile(path)
 	if err != nil {
@@ -125,13 +190,14 @@ func (s *Storage) read(path string, removeWhitespace bool) (body []byte, err err
 	if removeWhitespace && s.opts.removeWhitespace {
 		body = CleanWhitespaces(body)
 	}
-	s.cache.files.Set(path, body, cache.DefaultExpiration)
+	s.cache.files.Set(path, &file{body: body, modTime: info.ModTime()}, cache.DefaultExpiration)
 	return
 }
 
 // FlushCache - Flushes storage cache.
 func (s *Storage) FlushCache() {
 	s.cache.files.Flush()
+	s.cache.modTimes.Flush()
 	s.cache.templates.Flush()
 	s.cache.components.Flush()
 }
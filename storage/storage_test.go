@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStorageModTime(t *testing.T) {
+	dir, err := ioutil.TempDir("", "storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s, err := New(WithDir(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !s.ComponentModTime("test").IsZero() {
+		t.Fatal("Expected zero time of not existing component")
+	}
+	os.MkdirAll(filepath.Join(dir, "test"), 0755)
+	if err := ioutil.WriteFile(filepath.Join(dir, "test", "component.yaml"), []byte("main: test"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Modification time is cached until cache is flushed
+	if !s.ComponentModTime("test").IsZero() {
+		t.Error("Expected cached zero time")
+	}
+	s.FlushCache()
+	if s.ComponentModTime("test").IsZero() {
+		t.Error("Expected modification time after cache flush")
+	}
+}
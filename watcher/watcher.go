@@ -18,6 +18,16 @@ type CacheFlusher interface {
 	FlushCache()
 }
 
+// Flushers - List of cache flushers flushed together.
+type Flushers []CacheFlusher
+
+// FlushCache - Flushes all caches.
+func (flushers Flushers) FlushCache() {
+	for _, f := range flushers {
+		f.FlushCache()
+	}
+}
+
 // Start - Creates a new watcher which flushes caches.
 // Starts it in a separate goroutine.
 func Start(path string, cache CacheFlusher) (w *Watcher, err error) {